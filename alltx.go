@@ -0,0 +1,62 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+)
+
+var concurrentlyRe = regexp.MustCompile(`(?i)\bCONCURRENTLY\b`)
+
+// beginSharedTx starts the single transaction a --all-in-one-tx run applies
+// names in, so that either all of them apply or none does.
+func beginSharedTx(d *Dbmig, names []string) error {
+	for _, fname := range names {
+		if isGoMigration(fname) {
+			continue
+		}
+		data, err := readMigrationFile(d, fname)
+		if err != nil {
+			return err
+		}
+		if concurrentlyRe.MatchString(data) {
+			d.logf("Warning: %s mentions CONCURRENTLY. CREATE INDEX CONCURRENTLY and the like can't run inside a transaction, and will fail the whole --all-in-one-tx run", fname)
+		}
+	}
+
+	level, err := parseIsolationLevel(d.config.Isolation)
+	if err != nil {
+		return err
+	}
+
+	tx, err := beginMigrationTx(d.context(), d, level)
+	if err != nil {
+		return err
+	}
+	d.opts.sharedTx, d.opts.sharedLevel = tx, level
+	d.logf("Applying %d migrations in a single transaction", len(names))
+
+	return nil
+}
+
+// finishSharedTx commits the transaction of a --all-in-one-tx run when it
+// succeeded, that is err is nil, and rolls it back otherwise.
+func finishSharedTx(d *Dbmig, err error) error {
+	tx := d.opts.sharedTx
+	if tx == nil {
+		return err
+	}
+	d.opts.sharedTx, d.opts.sharedLevel = nil, sql.LevelDefault
+
+	if err != nil {
+		tx.Rollback()
+		d.logf("Rolled back the whole run, no migration was applied")
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("Committing the migrations of the run: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+//go:build sqlite
+// +build sqlite
+
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+var amountMigrations = map[string]string{
+	"1600000000_a.sql": "CREATE TABLE a (id INTEGER);\n/*DOWN*/\nDROP TABLE a;\n",
+	"1600000100_b.sql": "CREATE TABLE b (id INTEGER);\n/*DOWN*/\nDROP TABLE b;\n",
+	"1600000200_c.sql": "CREATE TABLE c (id INTEGER);\n/*DOWN*/\nDROP TABLE c;\n",
+}
+
+func TestLimitAmount(t *testing.T) {
+	names := []string{"a", "b", "c"}
+	tests := []struct {
+		amount int
+		want   string
+	}{
+		{0, "[a b c]"},
+		{1, "[a]"},
+		{2, "[a b]"},
+		{5, "[a b c]"},
+		{-1, "[a b c]"},
+	}
+
+	for _, tt := range tests {
+		if got := fmt.Sprint(limitAmount(names, tt.amount)); got != tt.want {
+			t.Errorf("limitAmount(%d) = %s, want %s", tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestMigrateAmount(t *testing.T) {
+	tests := []struct {
+		amount   string
+		wantUp   int
+		wantDown int
+	}{
+		// up starts from none applied, down from all three.
+		{"0", 3, 3},
+		{"all", 3, 3},
+		{"1", 1, 1},
+		{"2", 2, 2},
+		{"5", 3, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.amount, func(t *testing.T) {
+			d := newTestDbmig(t, amountMigrations)
+			mustRun(t, d, "migrate up "+tt.amount)
+			if got := len(appliedNames(t, d)); got != tt.wantUp {
+				t.Errorf("migrate up %s applied %d migrations, want %d", tt.amount, got, tt.wantUp)
+			}
+
+			mustRun(t, d, "migrate up all")
+			mustRun(t, d, "migrate down "+tt.amount)
+			if got := 3 - len(appliedNames(t, d)); got != tt.wantDown {
+				t.Errorf("migrate down %s reverted %d migrations, want %d", tt.amount, got, tt.wantDown)
+			}
+		})
+	}
+}
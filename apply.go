@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// stdinPrefix starts the names apply records migrations piped on stdin as.
+const stdinPrefix = "stdin-"
+
+var stdinMigrationRe = regexp.MustCompile(`^` + stdinPrefix + `[0-9]+$`)
+
+// Apply runs the up section of a single migration: a pending file from the
+// migrations folder, or with `-` a migration piped on stdin, which is
+// recorded as stdin-<timestamp>, without a checksum.
+func (d *Dbmig) Apply(args []string) error {
+	if len(args) == 0 || args[0] != "apply" {
+		return fmt.Errorf("Invalid call %v", args)
+	}
+
+	fs := flag.NewFlagSet("apply", flag.ContinueOnError)
+	fs.BoolVar(&d.opts.noRecord, "no-record", false, "Run the migration SQL without updating the tracking table (test harnesses only)")
+	positional, err := parseFlags(fs, args[1:])
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("Usage: apply <migration|->")
+	}
+	fname := positional[0]
+
+	if d.opts.noRecord {
+		d.logf("Warning: --no-record leaves the tracking table out of sync with the database, it is meant for test harnesses only")
+	}
+
+	if err := requireTrackingTable(d); err != nil {
+		return err
+	}
+
+	release, err := acquireLock(d)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	warnNonTransactionalDDL(d)
+
+	if fname == "-" {
+		name := fmt.Sprintf("%s%d", stdinPrefix, time.Now().Unix())
+		return runMigrationWith(d, name, "up", func() error {
+			return applyMigrationFrom(d, name, os.Stdin, "up")
+		})
+	}
+
+	pending, _, err := pendingMigrations(d)
+	if err != nil {
+		return err
+	}
+	if indexOf(pending, fname) < 0 {
+		if indexOf(migrationFilenames(d.config), fname) < 0 {
+			return fmt.Errorf("Unknown migration %s", fname)
+		}
+		return fmt.Errorf("Migration %s is already applied", fname)
+	}
+
+	return runMigration(d, fname, "up")
+}
+
+// isStdinMigration tells whether name is the tracking row of a migration
+// applied from stdin, which has no file and no checksum.
+func isStdinMigration(name string) bool {
+	return stdinMigrationRe.MatchString(name)
+}
+
+// guardStdinMigrations refuses to migrate down migrations applied from
+// stdin: their down section was never kept.
+func guardStdinMigrations(d *Dbmig, names []string) error {
+	piped := make([]string, 0)
+	for _, fname := range names {
+		if isStdinMigration(fname) {
+			piped = append(piped, fname)
+		}
+	}
+
+	if len(piped) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("Cannot migrate down %s, applied from stdin without a file to read the down section from. Undo them by hand, then delete their rows from %s", strings.Join(piped, ", "), d.config.trackingTableName())
+}
@@ -0,0 +1,56 @@
+//go:build sqlite
+// +build sqlite
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestStdinMigration(t *testing.T) {
+	d := newTestDbmig(t, nil)
+
+	name := stdinPrefix + "1600000000"
+	src := strings.NewReader("CREATE TABLE a (id INTEGER);\n/*DOWN*/\nDROP TABLE a;\n")
+	if err := runMigrationWith(d, name, "up", func() error {
+		return applyMigrationFrom(d, name, src, "up")
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	checksums, err := storedChecksums(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checksum, ok := checksums[name]; ok {
+		t.Errorf("recorded checksum %q for %s, want none", checksum, name)
+	}
+
+	mustRun(t, d, "verify")
+
+	for _, line := range []string{"migrate down", "migrate redo"} {
+		err := runCommand(d, strings.Fields(line))
+		if err == nil || !strings.Contains(err.Error(), "applied from stdin") {
+			t.Errorf("%s: got %v, want a refusal of %s", line, err, name)
+		}
+	}
+	if got := appliedNames(t, d); fmt.Sprint(got) != "["+name+"]" {
+		t.Errorf("applied %v, want [%s]", got, name)
+	}
+}
+
+func TestApplyNoRecord(t *testing.T) {
+	d := newTestDbmig(t, map[string]string{
+		"1600000000_a.sql": "CREATE TABLE a (id INTEGER);\n/*DOWN*/\nDROP TABLE a;\n",
+	})
+
+	mustRun(t, d, "apply --no-record 1600000000_a.sql")
+	if got := appliedNames(t, d); len(got) != 0 {
+		t.Errorf("apply --no-record recorded %v", got)
+	}
+	if _, err := d.db.Exec(`SELECT id FROM a`); err != nil {
+		t.Errorf("apply --no-record didn't run the migration: %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// testSuffix names the assertion file that goes with a migration, like
+// 1609459200_create_items.test.sql for 1609459200_create_items.sql.
+const testSuffix string = ".test.sql"
+
+const defaultTestTemplate string = `-- Assertions checked after %s is applied, with migrate --run-tests.
+-- Each statement must return a single true value, for instance:
+-- SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'items');
+`
+
+func isTestFile(fname string) bool {
+	return strings.HasSuffix(fname, testSuffix)
+}
+
+func testFileFor(fname string) string {
+	return strings.TrimSuffix(fname, ".sql") + testSuffix
+}
+
+// testTemplate returns the contents of a new assertion file for the
+// migration fname, from db_test_template when configured.
+func (c *Config) testTemplate(fname string) (string, error) {
+	if c.TestTemplate == "" {
+		return fmt.Sprintf(defaultTestTemplate, fname), nil
+	}
+
+	data, err := ioutil.ReadFile(c.TestTemplate)
+	if err != nil {
+		return "", fmt.Errorf("Cannot read db_test_template: %v", err)
+	}
+
+	return strings.Replace(string(data), "{migration}", fname, -1), nil
+}
+
+// runMigrationTest checks the assertions of the migration fname, if it has
+// any, within its transaction so a failing assertion rolls it back.
+func runMigrationTest(ctx context.Context, d *Dbmig, tx *sql.Tx, fname string) error {
+	testName := testFileFor(fname)
+	data, err := readMigrationFile(d, testName)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	stmts, err := splitStatements(data)
+	if err != nil {
+		return fmt.Errorf("Reading %s: %v", testName, err)
+	}
+
+	for _, stmt := range stmts {
+		if onlyComments(stmt) {
+			continue
+		}
+
+		var ok bool
+		if err := tx.QueryRowContext(ctx, stmt).Scan(&ok); err != nil {
+			return fmt.Errorf("Assertion of %s failed: %v\n  statement:\n%s", fname, err, stmt)
+		}
+		if !ok {
+			return fmt.Errorf("Assertion of %s returned false, rolled back\n  statement:\n%s", fname, stmt)
+		}
+	}
+
+	d.logf("%d assertions of %s passed", len(stmts), fname)
+	return nil
+}
+
+// onlyComments reports whether stmt has nothing but -- comments.
+func onlyComments(stmt string) bool {
+	for _, line := range strings.Split(stmt, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// trackingSnapshot is the JSON written by backupTrackingTable. Rows keep
+// every column of the tracking table, keyed by column name.
+type trackingSnapshot struct {
+	Table   string                   `json:"table"`
+	TakenAt time.Time                `json:"taken_at"`
+	Rows    []map[string]interface{} `json:"rows"`
+}
+
+var columnNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// backupTrackingTable writes the tracking table contents to a timestamped
+// file in dir and returns its path.
+func backupTrackingTable(d *Dbmig, dir string) (string, error) {
+	if err := maybeCreateFolder(d, dir); err != nil {
+		return "", err
+	}
+
+	ctx, cancel := d.statementContext()
+	defer cancel()
+
+	table := d.config.trackingTable()
+	rows, err := d.db.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %s ORDER BY id", table))
+	if err != nil {
+		return "", trackingTableError(d, err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+
+	snapshot := trackingSnapshot{Table: d.config.trackingTableName(), TakenAt: time.Now().UTC(), Rows: make([]map[string]interface{}, 0)}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return "", err
+		}
+
+		row := map[string]interface{}{}
+		for i, column := range columns {
+			if b, ok := values[i].([]byte); ok {
+				values[i] = string(b)
+			}
+			row[column] = values[i]
+		}
+		snapshot.Rows = append(snapshot.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	fname := fmt.Sprintf("%s-%s.json", strings.Replace(d.config.trackingTableName(), ".", "-", -1), snapshot.TakenAt.Format("20060102T150405Z"))
+	fpath := filepath.Join(dir, fname)
+	if err := ioutil.WriteFile(fpath, data, 0644); err != nil {
+		return "", err
+	}
+
+	d.logf("Tracking table %s backed up to %s (%d rows)", snapshot.Table, fpath, len(snapshot.Rows))
+	return fpath, nil
+}
+
+// RestoreTracking replaces the tracking table contents with a snapshot
+// taken by --backup-dir, without running any migration SQL. It holds the
+// migration lock, so it can't race a migrate run writing the table.
+func (d *Dbmig) RestoreTracking(args []string) error {
+	if len(args) == 0 || args[0] != "restore-tracking" {
+		return fmt.Errorf("Invalid call %v", args)
+	}
+
+	var yes bool
+	fs := flag.NewFlagSet("restore-tracking", flag.ContinueOnError)
+	fs.BoolVar(&yes, "yes", false, "Restore without asking")
+
+	positional, err := parseFlags(fs, args[1:])
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("Usage: %s restore-tracking <file>", programName)
+	}
+
+	data, err := ioutil.ReadFile(positional[0])
+	if err != nil {
+		return err
+	}
+
+	var snapshot trackingSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("Invalid snapshot %s: %v", positional[0], err)
+	}
+
+	table := d.config.trackingTable()
+	if !yes {
+		ok, err := confirm(d, fmt.Sprintf("Replace the contents of %s with %d rows from %s?", d.config.trackingTableName(), len(snapshot.Rows), positional[0]))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("Restore aborted")
+		}
+	}
+
+	release, err := acquireLock(d)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	ctx, cancel := d.statementContext()
+	defer cancel()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s", table)); err != nil {
+		return trackingTableError(d, err)
+	}
+
+	for _, row := range snapshot.Rows {
+		columns := make([]string, 0, len(row))
+		placeholders := make([]string, 0, len(row))
+		values := make([]interface{}, 0, len(row))
+		for column, value := range row {
+			if !columnNameRe.MatchString(column) {
+				return fmt.Errorf("Invalid column %q in snapshot", column)
+			}
+			columns = append(columns, d.dialect().quoteIdent(column))
+			values = append(values, value)
+			placeholders = append(placeholders, fmt.Sprintf("$%d", len(values)))
+		}
+
+		stmt := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+		if _, err := tx.ExecContext(ctx, d.rebind(stmt), values...); err != nil {
+			return err
+		}
+	}
+
+	if d.dialect().resetSequence != "" {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(d.dialect().resetSequence, table)); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	d.printf("Restored %d rows into %s\n", len(snapshot.Rows), d.config.trackingTableName())
+
+	return nil
+}
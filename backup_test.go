@@ -0,0 +1,61 @@
+//go:build sqlite
+// +build sqlite
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRedoAllBackup(t *testing.T) {
+	d := newTestDbmig(t, map[string]string{
+		"1600000000_a.sql": "CREATE TABLE a (id INTEGER);\n/*DOWN*/\nDROP TABLE a;\n",
+		"1600000100_b.sql": "CREATE TABLE b (id INTEGER);\n/*DOWN*/\nDROP TABLE b;\n",
+	})
+	mustRun(t, d, "migrate up all")
+
+	dir := t.TempDir()
+	mustRun(t, d, "migrate redo --all --yes --backup-dir "+dir)
+
+	snapshots, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 1 {
+		t.Fatalf("redo --all wrote %d snapshots, want 1", len(snapshots))
+	}
+
+	mustRun(t, d, "restore-tracking --yes "+snapshots[0])
+	if got := appliedNames(t, d); fmt.Sprint(got) != "[1600000000_a.sql 1600000100_b.sql]" {
+		t.Errorf("restored %v, want both migrations applied", got)
+	}
+}
+
+func TestRestoreTrackingWaitsForLock(t *testing.T) {
+	d := newTestDbmig(t, map[string]string{
+		"1600000000_a.sql": "CREATE TABLE a (id INTEGER);\n/*DOWN*/\nDROP TABLE a;\n",
+	})
+	mustRun(t, d, "migrate up")
+
+	snapshot, err := backupTrackingTable(d, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another run holds the migration lock.
+	release, err := acquireLock(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := runCommand(d.WithContext(ctx), []string{"restore-tracking", "--yes", snapshot}); err == nil {
+		t.Error("restore-tracking ran while the lock was held")
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// baseNameRows maps the applied migrations tracked under the base name of a
+// migration in a subfolder, as dbmi named them before naming them by their
+// path relative to the migrations folder, to that path. A base name shared
+// by several migrations in subfolders can't be told apart and is an error.
+func baseNameRows(applied []string, available []string) (map[string]string, error) {
+	present := toSet(available)
+	byBase := map[string][]string{}
+	for _, fname := range available {
+		if strings.Contains(fname, "/") {
+			byBase[path.Base(fname)] = append(byBase[path.Base(fname)], fname)
+		}
+	}
+
+	rows := map[string]string{}
+	for _, name := range applied {
+		if present[name] || strings.Contains(name, "/") {
+			continue
+		}
+		switch candidates := byBase[name]; len(candidates) {
+		case 0:
+		case 1:
+			rows[name] = candidates[0]
+		default:
+			return nil, fmt.Errorf("Applied migration %s may be any of %s, rename its tracking row to the one it is", name, strings.Join(candidates, ", "))
+		}
+	}
+
+	return rows, nil
+}
+
+// checkBaseNameRows refuses to go on while applied migrations in subfolders
+// are tracked under their base name: they would look pending and be applied
+// again.
+func checkBaseNameRows(applied []string, available []string) error {
+	rows, err := baseNameRows(applied, available)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(rows))
+	for name, fname := range rows {
+		names = append(names, fmt.Sprintf("%s (%s)", name, fname))
+	}
+	sort.Strings(names)
+
+	return fmt.Errorf("Applied migrations in subfolders are tracked under their base name, as an older dbmi did: %s. Run dbmi init to track them under their path", strings.Join(names, ", "))
+}
+
+// upgradeBaseNameRows renames the tracking rows found by baseNameRows to
+// the path of their migration.
+func upgradeBaseNameRows(d *Dbmig) error {
+	applied, err := appliedMigrations(d.context(), d, -1, false)
+	if err != nil {
+		return err
+	}
+
+	rows, err := baseNameRows(applied, migrationFilenames(d.config))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := d.statementContext()
+	defer cancel()
+
+	stmt := d.rebind(fmt.Sprintf(`UPDATE %s SET name = $1%s`, d.config.trackingTable(), d.config.whereApplied("name = $2")))
+	for name, fname := range rows {
+		if _, err := d.db.ExecContext(ctx, stmt, fname, name); err != nil {
+			return err
+		}
+		d.logf("Tracking %s under its path %s", name, fname)
+	}
+
+	return nil
+}
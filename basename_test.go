@@ -0,0 +1,47 @@
+//go:build sqlite
+// +build sqlite
+
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSubfolderMigrationTrackedUnderItsBaseName(t *testing.T) {
+	d := newTestDbmig(t, map[string]string{
+		"1600000000_a.sql":           "CREATE TABLE a (id INTEGER);\n/*DOWN*/\nDROP TABLE a;\n",
+		"users/1600000100_users.sql": "CREATE TABLE users (id INTEGER);\n/*DOWN*/\nDROP TABLE users;\n",
+	})
+	mustRun(t, d, "migrate up all")
+
+	// Track the subfolder migration the way older versions did.
+	if _, err := d.db.Exec(`UPDATE db_migrations SET name = '1600000100_users.sql' WHERE name = 'users/1600000100_users.sql'`); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runCommand(d, []string{"migrate", "up", "all"}); err == nil {
+		t.Fatal("migrate up ran with a subfolder migration tracked under its base name")
+	}
+	if err := runCommand(d, []string{"status"}); err == nil {
+		t.Fatal("status ran with a subfolder migration tracked under its base name")
+	}
+
+	mustRun(t, d, "init")
+
+	want := "[1600000000_a.sql users/1600000100_users.sql]"
+	if got := appliedNames(t, d); fmt.Sprint(got) != want {
+		t.Errorf("after init, applied %v, want %s", got, want)
+	}
+	mustRun(t, d, "migrate up all")
+	if got := appliedNames(t, d); fmt.Sprint(got) != want {
+		t.Errorf("migrate up applied the subfolder migration again: %v", got)
+	}
+}
+
+func TestBaseNameRowsAmbiguous(t *testing.T) {
+	_, err := baseNameRows([]string{"1600000000_x.sql"}, []string{"a/1600000000_x.sql", "b/1600000000_x.sql"})
+	if err == nil {
+		t.Fatal("a base name shared by two subfolder migrations was matched")
+	}
+}
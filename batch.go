@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// batchResult collects the outcome of each migration of a run that
+// continues past failures.
+type batchResult struct {
+	succeeded []string
+	failed    []string
+	errs      []error
+}
+
+func (r *batchResult) ok(fname string) {
+	r.succeeded = append(r.succeeded, fname)
+}
+
+func (r *batchResult) fail(fname string, err error) {
+	r.failed = append(r.failed, fname)
+	r.errs = append(r.errs, err)
+}
+
+// err summarizes the failures, or returns nil when there were none.
+func (r *batchResult) err() error {
+	if len(r.failed) == 0 {
+		return nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d of %d migrations failed", len(r.failed), len(r.failed)+len(r.succeeded))
+	if len(r.succeeded) > 0 {
+		fmt.Fprintf(&b, "\nsucceeded: %s", strings.Join(r.succeeded, ", "))
+	}
+	for i, fname := range r.failed {
+		fmt.Fprintf(&b, "\nfailed: %s: %v", fname, r.errs[i])
+	}
+
+	return fmt.Errorf("%s", b.String())
+}
+
+// checkBatchRollback makes sure every migration of batch can be migrated
+// down, before --rollback-batch-on-failure applies any of them.
+func checkBatchRollback(d *Dbmig, batch []string, forceIrreversible bool) error {
+	for _, fname := range batch {
+		if gm, ok := goMigrations[fname]; ok {
+			if gm.down == nil {
+				return fmt.Errorf("--rollback-batch-on-failure can't roll back %s, it has no down function", fname)
+			}
+			continue
+		}
+
+		data, err := readMigrationFile(d, fname)
+		if err != nil {
+			return err
+		}
+		if m, ok := parseMigration(fname, data); ok && m.forwardOnly() {
+			return fmt.Errorf("--rollback-batch-on-failure can't roll back %s, it is forward-only", fname)
+		}
+	}
+
+	return guardIrreversible(d, batch, forceIrreversible)
+}
+
+// rollbackBatch migrates down the migrations applied by this run, newest
+// first, after a later one failed with cause.
+func rollbackBatch(d *Dbmig, applied []string, cause error) error {
+	if len(applied) == 0 {
+		return cause
+	}
+
+	d.logf("Rolling back the %d migrations applied by this run: %v", len(applied), applied)
+	for i := len(applied) - 1; i >= 0; i-- {
+		if err := runMigration(d, applied[i], "down"); err != nil {
+			return fmt.Errorf("%v\nRolling back the batch failed at %s, %s stay applied: %v", cause, applied[i], strings.Join(applied[:i+1], ", "), err)
+		}
+	}
+
+	return fmt.Errorf("%v\nRolled back the migrations applied before it: %s", cause, strings.Join(applied, ", "))
+}
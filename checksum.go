@@ -0,0 +1,240 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// migrationChecksum is the SHA-256 of a migration's normalized contents.
+func migrationChecksum(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// storedChecksums returns the checksum recorded for each applied migration.
+// Migrations applied before checksums were recorded are left out.
+func storedChecksums(d *Dbmig) (map[string]string, error) {
+	ctx, cancel := d.statementContext()
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, fmt.Sprintf("SELECT name, checksum FROM %s%s", d.config.trackingTable(), d.config.whereApplied()))
+	if err != nil {
+		return nil, trackingTableError(d, err)
+	}
+	defer rows.Close()
+
+	checksums := map[string]string{}
+	for rows.Next() {
+		var name string
+		var checksum sql.NullString
+		if err := rows.Scan(&name, &checksum); err != nil {
+			return nil, err
+		}
+		if checksum.Valid {
+			checksums[name] = checksum.String
+		}
+	}
+
+	return checksums, rows.Err()
+}
+
+// driftedMigrations returns the applied migrations whose file changed since
+// they were applied. Repeatable migrations are expected to change, and
+// migrations whose file is gone are left to migrate down to report.
+func driftedMigrations(d *Dbmig) ([]string, error) {
+	checksums, err := storedChecksums(d)
+	if err != nil {
+		return nil, err
+	}
+
+	drifted := make([]string, 0)
+	for _, fname := range migrationFilenames(d.config) {
+		stored, ok := checksums[fname]
+		if !ok || isGoMigration(fname) || isRepeatable(d, fname) {
+			continue
+		}
+
+		data, err := readMigrationFile(d, fname)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if migrationChecksum(data) != stored {
+			drifted = append(drifted, fname)
+		}
+	}
+
+	return drifted, nil
+}
+
+// FixChecksums records the current checksum of the files of applied
+// migrations, after an intended edit like a formatting change. Repeatable
+// migrations are left out, their new contents are yet to be applied.
+func (d *Dbmig) FixChecksums(args []string) error {
+	if len(args) == 0 || args[0] != "fix-checksums" {
+		return fmt.Errorf("Invalid call %v", args)
+	}
+
+	var yes bool
+	fs := flag.NewFlagSet("fix-checksums", flag.ContinueOnError)
+	fs.BoolVar(&yes, "yes", false, "Update the checksums without asking")
+
+	if _, err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+
+	release, err := acquireLock(d)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	applied, err := appliedMigrations(d.context(), d, -1, false)
+	if err != nil {
+		return err
+	}
+	checksums, err := storedChecksums(d)
+	if err != nil {
+		return err
+	}
+
+	updated := map[string]string{}
+	names := make([]string, 0)
+	for _, fname := range applied {
+		if isGoMigration(fname) || isRepeatable(d, fname) {
+			continue
+		}
+
+		data, err := readMigrationFile(d, fname)
+		if os.IsNotExist(err) {
+			d.logf("Skipping %s, its file no longer exists", fname)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if sum := migrationChecksum(data); sum != checksums[fname] {
+			updated[fname] = sum
+			names = append(names, fname)
+		}
+	}
+
+	if len(names) == 0 {
+		d.printf("Nothing to do, the checksums of all %d applied migrations match their files\n", len(applied))
+		return nil
+	}
+
+	for _, fname := range names {
+		d.printf("  %s\n", fname)
+	}
+	if !yes {
+		ok, err := confirm(d, fmt.Sprintf("Record the current contents of these %d migrations as applied?", len(names)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			d.printf("Checksums not updated\n")
+			return nil
+		}
+	}
+
+	tx, err := d.db.BeginTx(d.context(), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := d.rebind(fmt.Sprintf("UPDATE %s SET checksum = $1%s", d.config.trackingTable(), d.config.whereApplied("name = $2")))
+	for _, fname := range names {
+		ctx, cancel := d.statementContext()
+		_, err := tx.ExecContext(ctx, stmt, updated[fname], fname)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("Updating the checksum of %s: %v", fname, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	d.printf("Updated the checksums of %d migrations\n", len(names))
+
+	return nil
+}
+
+// Verify checks that the file of every applied migration is there and
+// unchanged since it was applied, like migrate does before migrating up but
+// without migrating. Applied migrations whose file is gone are reported,
+// unless --only-tracked is passed for folders where old migrations are
+// archived. Go and repeatable migrations, and rows recorded before
+// checksums were, are left out.
+func (d *Dbmig) Verify(args []string) error {
+	if len(args) == 0 || args[0] != "verify" {
+		return fmt.Errorf("Invalid call %v", args)
+	}
+
+	var onlyTracked bool
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	fs.BoolVar(&onlyTracked, "only-tracked", false, "Only check the applied migrations whose file still exists, skipping archived ones")
+
+	if _, err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+
+	if err := requireTrackingTable(d); err != nil {
+		return err
+	}
+
+	applied, err := appliedMigrations(d.context(), d, -1, false)
+	if err != nil {
+		return err
+	}
+	checksums, err := storedChecksums(d)
+	if err != nil {
+		return err
+	}
+
+	checked, archived, problems := 0, 0, 0
+	for _, fname := range applied {
+		stored, ok := checksums[fname]
+		if !ok || isGoMigration(fname) || isStdinMigration(fname) || isRepeatable(d, fname) {
+			continue
+		}
+
+		data, err := readMigrationFile(d, fname)
+		if os.IsNotExist(err) {
+			if onlyTracked {
+				archived++
+				continue
+			}
+			d.printf("%s\t%s\n", paint(colorStdout, colorRed, "missing"), fname)
+			problems++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		checked++
+		if migrationChecksum(data) != stored {
+			d.printf("%s\t%s\n", paint(colorStdout, colorRed, "changed"), fname)
+			problems++
+		}
+	}
+
+	if archived > 0 {
+		d.logf("Skipped %d applied migrations whose file no longer exists", archived)
+	}
+	if problems > 0 {
+		return fmt.Errorf("%d applied migrations don't match their files", problems)
+	}
+	d.printf("The files of all %d checked applied migrations match their checksums\n", checked)
+
+	return nil
+}
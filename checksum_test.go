@@ -0,0 +1,64 @@
+//go:build sqlite
+// +build sqlite
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var checksumMigrations = map[string]string{
+	"1600000000_a.sql": "CREATE TABLE a (id INTEGER);\n/*DOWN*/\nDROP TABLE a;\n",
+	"1600000100_b.sql": "CREATE TABLE b (id INTEGER);\n/*DOWN*/\nDROP TABLE b;\n",
+}
+
+func TestVerifyOnlyTracked(t *testing.T) {
+	d := newTestDbmig(t, checksumMigrations)
+	mustRun(t, d, "migrate up all")
+	mustRun(t, d, "verify")
+
+	// Archive the oldest migration.
+	if err := os.Remove(filepath.Join(d.config.Folder, "1600000000_a.sql")); err != nil {
+		t.Fatal(err)
+	}
+	if err := runCommand(d, []string{"verify"}); err == nil {
+		t.Error("verify passed with the file of an applied migration gone")
+	}
+	mustRun(t, d, "verify --only-tracked")
+
+	// A remaining file that changed still fails.
+	if err := ioutil.WriteFile(filepath.Join(d.config.Folder, "1600000100_b.sql"), []byte("CREATE TABLE b (id TEXT);\n/*DOWN*/\nDROP TABLE b;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runCommand(d, []string{"verify", "--only-tracked"}); err == nil {
+		t.Error("verify --only-tracked passed with an applied migration changed")
+	}
+}
+
+func TestMigrateUpStopsAtModifiedFile(t *testing.T) {
+	d := newTestDbmig(t, checksumMigrations)
+	mustRun(t, d, "migrate up 1")
+
+	// Edit the applied migration, and add a pending one.
+	writeTestMigrations(t, d.config.Folder, map[string]string{
+		"1600000000_a.sql": "CREATE TABLE a (id TEXT);\n/*DOWN*/\nDROP TABLE a;\n",
+	})
+
+	err := runCommand(d, []string{"migrate", "up", "all"})
+	if err == nil || !strings.Contains(err.Error(), "1600000000_a.sql") {
+		t.Fatalf("migrate up returned %v, want an error naming 1600000000_a.sql", err)
+	}
+	if got := fmt.Sprint(appliedNames(t, d)); got != "[1600000000_a.sql]" {
+		t.Errorf("migrate up applied migrations after the drift check failed: %s", got)
+	}
+
+	mustRun(t, d, "migrate up all --skip-checksum-check")
+	if got := fmt.Sprint(appliedNames(t, d)); got != "[1600000000_a.sql 1600000100_b.sql]" {
+		t.Errorf("with --skip-checksum-check, applied %s", got)
+	}
+}
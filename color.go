@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+)
+
+const (
+	colorReset  string = "\033[0m"
+	colorRed    string = "\033[31m"
+	colorGreen  string = "\033[32m"
+	colorYellow string = "\033[33m"
+)
+
+// colorStdout and colorStderr are set in main once flags are parsed.
+var (
+	colorStdout bool
+	colorStderr bool
+)
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// setupColors enables colored output on the streams that are terminals,
+// unless disabled with --no-color or the NO_COLOR environment variable.
+func setupColors(noColor bool) {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok || noColor {
+		return
+	}
+
+	colorStdout = isTerminal(os.Stdout)
+	colorStderr = isTerminal(os.Stderr)
+}
+
+func paint(enabled bool, color string, s string) string {
+	if !enabled {
+		return s
+	}
+
+	return color + s + colorReset
+}
+
+// stateColor picks the color for a migration state shown to the user.
+func stateColor(state string) string {
+	switch state {
+	case "applied":
+		return colorGreen
+	case "pending", "changed", "excluded":
+		return colorYellow
+	}
+
+	return colorRed
+}
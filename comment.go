@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+const defaultTableComment string = "Managed by dbmi — do not edit manually"
+
+// trackingColumnComments document the columns of the tracking table.
+var trackingColumnComments = []struct {
+	name    string
+	comment string
+}{
+	{"id", "Row id, in the order migrations were recorded"},
+	{"name", "Migration file, relative to the migrations folder"},
+	{"created_at", "When the migration was applied"},
+	{"version", "Position of the migration in the applied history"},
+	{"checksum", "SHA-256 of the migration file when it was applied"},
+	{"source_commit", "Git commit that last changed the migration file, with migrate --record-commit"},
+	{"release", "Release label of the run that applied the migration, with migrate --release"},
+	{"rolled_back_at", "When the migration was migrated down, with db_soft_delete"},
+}
+
+func (c *Config) tableComment() string {
+	if c.TableComment == "" {
+		return defaultTableComment
+	}
+
+	return c.TableComment
+}
+
+// commentTrackingTable explains what the tracking table and its columns are
+// for to whoever inspects the schema. Only Postgres has COMMENT ON.
+func commentTrackingTable(d *Dbmig) error {
+	if !d.config.isPostgres() {
+		return nil
+	}
+
+	ctx, cancel := d.statementContext()
+	defer cancel()
+
+	table := d.config.trackingTable()
+	if _, err := d.db.ExecContext(ctx, fmt.Sprintf("COMMENT ON TABLE %s IS %s", table, pq.QuoteLiteral(d.config.tableComment()))); err != nil {
+		return fmt.Errorf("Commenting tracking table '%s': %v", d.config.trackingTableName(), err)
+	}
+
+	for _, column := range trackingColumnComments {
+		query := fmt.Sprintf("COMMENT ON COLUMN %s.%s IS %s", table, column.name, pq.QuoteLiteral(column.comment))
+		if _, err := d.db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("Commenting column %s of tracking table '%s': %v", column.name, d.config.trackingTableName(), err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// completionCommand lists what the completion scripts offer after a command.
+type completionCommand struct {
+	name  string
+	args  []string
+	flags []string
+}
+
+// completionCommands mirrors dispatch and the flag sets of the commands;
+// keep it in sync when adding either.
+var completionCommands = []completionCommand{
+	{name: "init", flags: []string{"fail-if-exists", "table-owner", "table-comment", "with-example"}},
+	{name: "new", flags: []string{"output-dir", "empty", "no-down", "with-test", "format", "dir-from-name", "json"}},
+	{name: "migrate", args: []string{"up", "down", "redo", "to", "all"}, flags: []string{"no-record", "step", "yes", "all-in-one-tx", "continue-on-error", "rollback-batch-on-failure", "force-irreversible", "on-missing-file", "backup-dir", "dir-order", "skip-checksum-check", "skip-pre-check", "strict", "record-commit", "release", "run-tests", "enable-tokens", "check-connection-before-each", "verbose-errors", "savepoints", "skip-failed-statements", "timeout-per-migration", "max-statements", "only", "exclude", "all", "metrics-file"}},
+	{name: "status", flags: []string{"since", "before", "exclude", "since-version", "porcelain"}},
+	{name: "current", flags: []string{"with-age", "seconds"}},
+	{name: "history", flags: []string{"limit", "json", "release"}},
+	{name: "watch", flags: []string{"interval", "debounce"}},
+	{name: "serve"},
+	{name: "plan", flags: []string{"porcelain"}},
+	{name: "doctor"},
+	{name: "exec", args: []string{"-"}},
+	{name: "ping"},
+	{name: "apply", args: []string{"-"}, flags: []string{"no-record"}},
+	{name: "repair", flags: []string{"apply-missing", "yes"}},
+	{name: "export", args: []string{"up", "down"}, flags: []string{"to"}},
+	{name: "squash", flags: []string{"to", "name", "yes"}},
+	{name: "renumber", flags: []string{"start", "spacing", "dry-run"}},
+	{name: "rename", flags: []string{"keep-name"}},
+	{name: "move-tracking", flags: []string{"from", "to"}},
+	{name: "restore-tracking", flags: []string{"yes"}},
+	{name: "unlock", flags: []string{"yes"}},
+	{name: "verify", flags: []string{"only-tracked"}},
+	{name: "fix-checksums", flags: []string{"yes"}},
+	{name: "dump-schema", flags: []string{"output"}},
+	{name: "drift", flags: []string{"snapshot"}},
+	{name: "validate", flags: []string{"strict"}},
+	{name: "manifest", flags: []string{"check", "file"}},
+	{name: "drivers"},
+	{name: "completion", args: []string{"bash", "zsh", "fish"}},
+	{name: "exampleconf"},
+	{name: "version", flags: []string{"short", "json"}},
+	{name: "usage"},
+}
+
+func completionCommandNames() []string {
+	names := make([]string, 0, len(completionCommands))
+	for _, c := range completionCommands {
+		names = append(names, c.name)
+	}
+
+	return names
+}
+
+// globalFlags returns the flags accepted before the command, and those of
+// them that take a value.
+func globalFlags() ([]string, []string) {
+	var all, valued []string
+	flag.VisitAll(func(f *flag.Flag) {
+		all = append(all, f.Name)
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); !ok || !b.IsBoolFlag() {
+			valued = append(valued, f.Name)
+		}
+	})
+
+	return all, valued
+}
+
+func dashed(prefix string, names []string) []string {
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		result = append(result, prefix+name)
+	}
+
+	return result
+}
+
+// Completion prints a shell completion script for the commands and flags.
+func (d *Dbmig) Completion(args []string) error {
+	if len(args) != 2 || args[0] != "completion" {
+		return fmt.Errorf("Usage: %s completion <bash|zsh|fish>", programName)
+	}
+
+	switch args[1] {
+	case "bash":
+		fmt.Fprint(d.stdout(), bashCompletion())
+	case "zsh":
+		// zsh runs bash completion functions through bashcompinit.
+		fmt.Fprint(d.stdout(), "autoload -U +X bashcompinit && bashcompinit\n"+bashCompletion())
+	case "fish":
+		fmt.Fprint(d.stdout(), fishCompletion())
+	default:
+		return fmt.Errorf("Unknown shell %q, expected bash, zsh or fish", args[1])
+	}
+
+	return nil
+}
+
+func bashCompletion() string {
+	all, valued := globalFlags()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "_%s() {\n", programName)
+	fmt.Fprintf(&b, "\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\" cmd=\"\" skip=\"\" i w\n")
+	fmt.Fprintf(&b, "\tfor ((i = 1; i < COMP_CWORD; i++)); do\n")
+	fmt.Fprintf(&b, "\t\tw=\"${COMP_WORDS[i]}\"\n")
+	fmt.Fprintf(&b, "\t\tif [ -n \"$skip\" ]; then skip=\"\"; continue; fi\n")
+	fmt.Fprintf(&b, "\t\tcase \"$w\" in\n")
+	fmt.Fprintf(&b, "\t\t%s) skip=1 ;;\n", strings.Join(append(dashed("-", valued), dashed("--", valued)...), "|"))
+	fmt.Fprintf(&b, "\t\t-*) ;;\n")
+	fmt.Fprintf(&b, "\t\t*) cmd=\"$w\"; break ;;\n")
+	fmt.Fprintf(&b, "\t\tesac\n")
+	fmt.Fprintf(&b, "\tdone\n\n")
+	fmt.Fprintf(&b, "\tcase \"$cmd\" in\n")
+	fmt.Fprintf(&b, "\t\"\")\n")
+	fmt.Fprintf(&b, "\t\tif [[ \"$cur\" == -* ]]; then\n")
+	fmt.Fprintf(&b, "\t\t\tCOMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", strings.Join(dashed("-", all), " "))
+	fmt.Fprintf(&b, "\t\telse\n")
+	fmt.Fprintf(&b, "\t\t\tCOMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", strings.Join(completionCommandNames(), " "))
+	fmt.Fprintf(&b, "\t\tfi ;;\n")
+	for _, c := range completionCommands {
+		words := append(append([]string{}, c.args...), dashed("--", c.flags)...)
+		if len(words) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\t%s) COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")) ;;\n", c.name, strings.Join(words, " "))
+	}
+	fmt.Fprintf(&b, "\tesac\n")
+	fmt.Fprintf(&b, "}\n")
+	fmt.Fprintf(&b, "complete -o default -F _%[1]s %[1]s\n", programName)
+
+	return b.String()
+}
+
+func fishCompletion() string {
+	all, valued := globalFlags()
+	isValued := toSet(valued)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "complete -c %s -f\n", programName)
+	for _, name := range all {
+		required := ""
+		if isValued[name] {
+			required = " -r"
+		}
+		fmt.Fprintf(&b, "complete -c %s -n __fish_use_subcommand -o %s%s\n", programName, name, required)
+	}
+	fmt.Fprintf(&b, "complete -c %s -n __fish_use_subcommand -a '%s'\n", programName, strings.Join(completionCommandNames(), " "))
+	for _, c := range completionCommands {
+		condition := "__fish_seen_subcommand_from " + c.name
+		if len(c.args) > 0 {
+			fmt.Fprintf(&b, "complete -c %s -n '%s' -a '%s'\n", programName, condition, strings.Join(c.args, " "))
+		}
+		for _, f := range c.flags {
+			fmt.Fprintf(&b, "complete -c %s -n '%s' -l %s\n", programName, condition, f)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestDefaultConnectionStringParses(t *testing.T) {
+	c := defaultConfig()
+
+	opts, err := pq.ParseURL(c.ConnectionString)
+	if err != nil {
+		t.Fatalf("default connection string %q: %v", c.ConnectionString, err)
+	}
+	if !strings.Contains(opts, "sslmode=disable") {
+		t.Errorf("default connection string %q doesn't disable sslmode: %s", c.ConnectionString, opts)
+	}
+	if err := c.validate(); err != nil {
+		t.Errorf("default config is invalid: %v", err)
+	}
+}
+
+func TestDefaultConfigMatchesExample(t *testing.T) {
+	var example Config
+	if err := json.Unmarshal([]byte(configExample), &example); err != nil {
+		t.Fatal(err)
+	}
+
+	c := defaultConfig()
+	if c.Folder != example.Folder || c.Tablename != example.Tablename {
+		t.Errorf("defaults %s and %s, the example config has %s and %s", c.Folder, c.Tablename, example.Folder, example.Tablename)
+	}
+}
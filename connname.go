@@ -0,0 +1,33 @@
+package main
+
+import "strings"
+
+// connectionName labels the database in logs: connection_name, or the host
+// and database name of the connection string.
+func (c *Config) connectionName() string {
+	if c.ConnectionName != "" {
+		return c.ConnectionName
+	}
+
+	parts := make([]string, 0, 2)
+	for _, part := range []string{connectionHost(c.ConnectionString), connectionDatabase(c.ConnectionString)} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	return strings.Join(parts, "/")
+}
+
+// advisoryLockKey is hashed into the advisory lock key. Advisory locks are
+// per database already, so only an explicit connection_name is folded in:
+// a name derived from the connection string would differ between tools
+// reaching the same database through different host names, and they would
+// stop excluding each other.
+func (c *Config) advisoryLockKey() string {
+	if c.ConnectionName == "" {
+		return c.trackingTableName()
+	}
+
+	return c.ConnectionName + ":" + c.trackingTableName()
+}
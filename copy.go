@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// A section headed `-- dbmi:copy: items (id, name)` holds data rows to load
+// into the table instead of SQL, one row per line, tab separated, or comma
+// separated with a trailing `csv`. \N is NULL, and tab separated values use
+// the backslash escapes of COPY's text format. lib/pq loads them with COPY;
+// other drivers insert them row by row.
+
+// copyNull is the NULL of the data rows, like in COPY's text format.
+const copyNull string = `\N`
+
+var copyTargetRe = regexp.MustCompile(`(?i)^([^\s(]+)\s*\(([^)]*)\)\s*(csv|tsv)?$`)
+
+// copyTarget is the table and columns the rows of a copy section go to.
+type copyTarget struct {
+	table   string
+	columns []string
+	csv     bool
+}
+
+func parseCopyTarget(value string) (*copyTarget, error) {
+	match := copyTargetRe.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return nil, fmt.Errorf("Invalid copy header %q, expected <table> (<column>, ...) [csv|tsv]", value)
+	}
+
+	t := &copyTarget{table: match[1], csv: strings.EqualFold(match[3], "csv")}
+	for _, column := range strings.Split(match[2], ",") {
+		if column = strings.TrimSpace(column); column == "" {
+			return nil, fmt.Errorf("Invalid copy header %q, empty column name", value)
+		}
+		t.columns = append(t.columns, column)
+	}
+
+	return t, nil
+}
+
+// sectionCopyTarget returns the copy target of a migration section, or nil
+// when it is SQL.
+func sectionCopyTarget(section string) (*copyTarget, error) {
+	values, ok := parseDirectives(section)["copy"]
+	if !ok {
+		return nil, nil
+	}
+
+	return parseCopyTarget(values[len(values)-1])
+}
+
+// row splits a data line into the values of the columns.
+func (t *copyTarget) row(line string) ([]interface{}, error) {
+	var fields []string
+	if t.csv {
+		r := csv.NewReader(strings.NewReader(line))
+		r.FieldsPerRecord = len(t.columns)
+		var err error
+		if fields, err = r.Read(); err != nil {
+			return nil, err
+		}
+	} else {
+		fields = strings.Split(line, "\t")
+	}
+	if len(fields) != len(t.columns) {
+		return nil, fmt.Errorf("%d values for %d columns", len(fields), len(t.columns))
+	}
+
+	values := make([]interface{}, len(fields))
+	for i, field := range fields {
+		switch {
+		case field == copyNull:
+		case t.csv:
+			values[i] = field
+		default:
+			values[i] = unescapeCopyText(field)
+		}
+	}
+
+	return values, nil
+}
+
+// copyTextEscapes are the single character backslash escapes of COPY's text
+// format.
+var copyTextEscapes = map[byte]byte{'b': '\b', 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t', 'v': '\v'}
+
+// unescapeCopyText decodes the backslash escapes of a value in COPY's text
+// format, as the server does: \t and the like, octal \NNN and hex \xNN, and
+// a backslash before any other character, itself included, stands for it.
+// Values are passed decoded to either driver, so both load the same data.
+func unescapeCopyText(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(field); i++ {
+		c := field[i]
+		if c != '\\' || i+1 == len(field) {
+			b.WriteByte(c)
+			continue
+		}
+
+		i++
+		c = field[i]
+		switch {
+		case copyTextEscapes[c] != 0:
+			b.WriteByte(copyTextEscapes[c])
+		case c >= '0' && c <= '7':
+			n, j := 0, i
+			for ; j < len(field) && j < i+3 && field[j] >= '0' && field[j] <= '7'; j++ {
+				n = n*8 + int(field[j]-'0')
+			}
+			b.WriteByte(byte(n))
+			i = j - 1
+		case c == 'x' && i+1 < len(field) && isHexDigit(field[i+1]):
+			n, j := 0, i+1
+			for ; j < len(field) && j < i+3 && isHexDigit(field[j]); j++ {
+				n = n*16 + hexValue(field[j])
+			}
+			b.WriteByte(byte(n))
+			i = j - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
+
+func isHexDigit(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func hexValue(c byte) int {
+	switch {
+	case c >= 'a':
+		return int(c-'a') + 10
+	case c >= 'A':
+		return int(c-'A') + 10
+	}
+
+	return int(c - '0')
+}
+
+// isCopyRow tells whether line of a copy section is a data row. Blank lines
+// are skipped everywhere, -- comments such as the copy header only until the
+// first row, after which a value may well start with --.
+func isCopyRow(line string, started bool) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed != "" && (started || !strings.HasPrefix(trimmed, "--"))
+}
+
+// prepareCopy prepares the statement each row is executed with: COPY on
+// lib/pq, an INSERT otherwise.
+func prepareCopy(ctx context.Context, d *Dbmig, tx *sql.Tx, t *copyTarget) (*sql.Stmt, error) {
+	if d.config.driver() == "postgres" {
+		if i := strings.Index(t.table, "."); i >= 0 {
+			return tx.PrepareContext(ctx, pq.CopyInSchema(t.table[:i], t.table[i+1:], t.columns...))
+		}
+		return tx.PrepareContext(ctx, pq.CopyIn(t.table, t.columns...))
+	}
+
+	quote := d.dialect().quoteIdent
+	table := make([]string, 0, 2)
+	for _, part := range strings.SplitN(t.table, ".", 2) {
+		table = append(table, quote(part))
+	}
+	columns := make([]string, len(t.columns))
+	placeholders := make([]string, len(t.columns))
+	for i, column := range t.columns {
+		columns[i] = quote(column)
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+
+	stmt := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", strings.Join(table, "."), strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+	return tx.PrepareContext(ctx, d.rebind(stmt))
+}
+
+// copyRows loads the data rows read from r into the copy target, up to the
+// end of r or the line with the separator, and reports whether it read the
+// separator. Blank lines, and -- comments before the first row, are skipped.
+func copyRows(ctx context.Context, d *Dbmig, tx *sql.Tx, fname string, t *copyTarget, r *bufio.Reader) (bool, error) {
+	stmt, err := prepareCopy(ctx, d, tx, t)
+	if err != nil {
+		return false, fmt.Errorf("Copying into %s for %s: %v", t.table, fname, err)
+	}
+	defer stmt.Close()
+
+	separator := false
+	rows := 0
+	for line := 1; ; line++ {
+		text, err := r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return false, err
+		}
+		done := err == io.EOF
+
+		text = strings.TrimRight(text, "\r\n")
+		if strings.Contains(text, migrationSeparator) {
+			separator = true
+			break
+		}
+		if isCopyRow(text, rows > 0) {
+			values, err := t.row(text)
+			if err != nil {
+				return false, fmt.Errorf("Copying into %s for %s, line %d of the section: %v", t.table, fname, line, err)
+			}
+			if _, err := stmt.ExecContext(ctx, values...); err != nil {
+				return false, fmt.Errorf("Copying into %s for %s, line %d of the section: %v", t.table, fname, line, err)
+			}
+			rows++
+		}
+
+		if done {
+			break
+		}
+	}
+
+	// COPY sends the rows when executed without values.
+	if d.config.driver() == "postgres" {
+		if _, err := stmt.ExecContext(ctx); err != nil {
+			return false, fmt.Errorf("Copying into %s for %s: %v", t.table, fname, err)
+		}
+	}
+	d.logf("Copied %d rows into %s", rows, t.table)
+
+	return separator, nil
+}
+
+// psqlCopy returns the COPY of the rows of section, in the form psql runs,
+// for export.
+func (t *copyTarget) psqlCopy(section string) string {
+	var b strings.Builder
+	options := ""
+	if t.csv {
+		options = fmt.Sprintf(" WITH (FORMAT csv, NULL '%s')", copyNull)
+	}
+	fmt.Fprintf(&b, "COPY %s (%s) FROM stdin%s;\n", t.table, strings.Join(t.columns, ", "), options)
+	started := false
+	for _, line := range strings.Split(section, "\n") {
+		if isCopyRow(line, started) {
+			fmt.Fprintf(&b, "%s\n", strings.TrimRight(line, "\r"))
+			started = true
+		}
+	}
+	b.WriteString(`\.`)
+
+	return b.String()
+}
@@ -0,0 +1,67 @@
+//go:build sqlite
+// +build sqlite
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnescapeCopyText(t *testing.T) {
+	for field, want := range map[string]string{
+		`plain`:        "plain",
+		`a\tb`:         "a\tb",
+		`line\nbreak`:  "line\nbreak",
+		`back\\slash`:  `back\slash`,
+		`\101\x42\.`:   "AB.",
+		`trailing\`:    `trailing\`,
+		`\\N`:          `\N`,
+		`--not a note`: "--not a note",
+	} {
+		if got := unescapeCopyText(field); got != want {
+			t.Errorf("unescapeCopyText(%q) = %q, want %q", field, got, want)
+		}
+	}
+}
+
+func TestCopyRows(t *testing.T) {
+	d := newTestDbmig(t, map[string]string{
+		"1600000000_items.sql": "CREATE TABLE items (id INTEGER, name TEXT);\n/*DOWN*/\nDROP TABLE items;\n",
+		"1600000100_seed.sql": "-- dbmi:copy: items (id, name)\n-- a comment before the rows\n" +
+			"1\ta\\tb\n2\t\\N\n--3\tdashes\n\n4\tback\\\\slash\n/*DOWN*/\nDELETE FROM items;\n",
+	})
+	mustRun(t, d, "migrate up all")
+
+	rows, err := d.db.Query(`SELECT id, COALESCE(name, 'NULL') FROM items ORDER BY rowid`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	got := make([]string, 0)
+	for rows.Next() {
+		var id, name string
+		if err := rows.Scan(&id, &name); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, id+"="+name)
+	}
+	want := "[1=a\tb 2=NULL --3=dashes 4=back\\slash]"
+	if s := "[" + strings.Join(got, " ") + "]"; s != want {
+		t.Errorf("loaded %q, want %q", s, want)
+	}
+}
+
+func TestPsqlCopyKeepsDashRows(t *testing.T) {
+	target, err := sectionCopyTarget("-- dbmi:copy: items (id, name)\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := target.psqlCopy("-- dbmi:copy: items (id, name)\n-- note\n1\tx\n--flag\ty\n")
+	want := "COPY items (id, name) FROM stdin;\n1\tx\n--flag\ty\n\\."
+	if got != want {
+		t.Errorf("psqlCopy = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,148 @@
+//go:build sqlite
+// +build sqlite
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestDbmig returns a Dbmig on a fresh SQLite database, with the
+// migrations files written to its folder and the tracking table created.
+func newTestDbmig(t *testing.T, files map[string]string) *Dbmig {
+	t.Helper()
+
+	dir := t.TempDir()
+	folder := filepath.Join(dir, "migrations")
+	writeTestMigrations(t, folder, files)
+
+	config := defaultConfig()
+	config.Driver = "sqlite3"
+	config.ConnectionString = "file:" + filepath.Join(dir, "test.db")
+	config.Folder = folder
+
+	db, err := sql.Open("sqlite3", config.ConnectionString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	d, err := NewDbmig(context.Background(), config, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.SetOutput(ioutil.Discard, ioutil.Discard)
+
+	mustRun(t, d, "init")
+
+	return d
+}
+
+func writeTestMigrations(t *testing.T, folder string, files map[string]string) {
+	t.Helper()
+
+	for name, data := range files {
+		fpath := filepath.Join(folder, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fpath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fpath, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// mustRun runs the command line on d and fails the test when it fails.
+func mustRun(t *testing.T, d *Dbmig, line string) {
+	t.Helper()
+
+	if err := runCommand(d, strings.Fields(line)); err != nil {
+		t.Fatalf("%s: %v", line, err)
+	}
+}
+
+// appliedNames returns the applied migrations in the order they were
+// applied.
+func appliedNames(t *testing.T, d *Dbmig) []string {
+	t.Helper()
+
+	names, err := appliedMigrations(context.Background(), d, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return names
+}
+
+func TestRunMigrationTxBodyLongerThanStatementTimeout(t *testing.T) {
+	d := newTestDbmig(t, nil)
+	d.config.StatementTimeout = "50ms"
+
+	m, _ := parseMigration("1600000000_slow.sql", "SELECT 1;\n/*DOWN*/\n")
+	body := func(ctx context.Context, tx *sql.Tx, timeout time.Duration) error {
+		time.Sleep(200 * time.Millisecond)
+		_, err := tx.ExecContext(ctx, "SELECT 1")
+		return err
+	}
+	checksum := func() string { return migrationChecksum(m.Up + migrationSeparator + m.Down) }
+
+	if err := runMigrationTx(d, m, "up", body, checksum); err != nil {
+		t.Fatalf("migration running longer than the statement timeout failed: %v", err)
+	}
+
+	if got := appliedNames(t, d); fmt.Sprint(got) != "[1600000000_slow.sql]" {
+		t.Errorf("applied %v, want [1600000000_slow.sql]", got)
+	}
+}
+
+func TestCallerContext(t *testing.T) {
+	d := newTestDbmig(t, map[string]string{
+		"1600000000_a.sql": "CREATE TABLE a (id INTEGER);\n/*DOWN*/\nDROP TABLE a;\n",
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := d.InitMigrations(ctx); err == nil {
+		t.Error("InitMigrations ran on a cancelled context")
+	}
+	if err := applyMigration(ctx, d, "1600000000_a.sql", "up"); err == nil {
+		t.Error("applyMigration ran on a cancelled context")
+	}
+	if _, err := appliedMigrations(ctx, d, 0, false); err == nil {
+		t.Error("appliedMigrations ran on a cancelled context")
+	}
+
+	if err := applyMigration(context.Background(), d, "1600000000_a.sql", "up"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetOutputCapturesLogs(t *testing.T) {
+	d := newTestDbmig(t, map[string]string{
+		"notes.sql": "SELECT 1;\n",
+	})
+
+	var stdout, stderr bytes.Buffer
+	d.SetOutput(&stdout, &stderr)
+	mustRun(t, d, "status")
+
+	if !strings.Contains(stderr.String(), "Ignoring") {
+		t.Errorf("stderr %q doesn't say notes.sql is ignored", stderr.String())
+	}
+	if strings.Contains(stdout.String(), "Ignoring") {
+		t.Errorf("stdout %q has the log lines", stdout.String())
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// Doctor checks the setup dbmi runs in, and shows what the configured
+// driver supports.
+func (d *Dbmig) Doctor(args []string) error {
+	if len(args) == 0 || args[0] != "doctor" {
+		return fmt.Errorf("Invalid call %v", args)
+	}
+
+	problems := 0
+	report := func(state string, check string, detail string) {
+		if state == "fail" {
+			problems++
+		}
+		color := colorGreen
+		switch state {
+		case "warn":
+			color = colorYellow
+		case "fail":
+			color = colorRed
+		}
+		d.printf("%s\t%-22s\t%s\n", paint(colorStdout, color, state), check, detail)
+	}
+	yesNo := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
+
+	dialect := d.dialect()
+	report("ok", "driver", dialect.driver)
+	report("ok", "connection", redactPassword(d.config.ConnectionString, d.config.ConnectionString))
+
+	if dialect.transactionalDDL {
+		report("ok", "transactional DDL", "yes, a failed migration is rolled back entirely")
+	} else {
+		report("warn", "transactional DDL", "no, a failed migration can leave the database partially migrated")
+	}
+	report("ok", "RETURNING", yesNo(d.supportsReturning()))
+	report("ok", "schemas", yesNo(dialect.schemas))
+	report("ok", "lock strategy", d.config.lockStrategy())
+
+	if info, err := os.Stat(d.config.Folder); err != nil {
+		report("fail", "migrations folder", err.Error())
+	} else if !info.IsDir() {
+		report("fail", "migrations folder", fmt.Sprintf("%s is not a directory", d.config.Folder))
+	} else {
+		report("ok", "migrations folder", fmt.Sprintf("%s, %d migrations", d.config.Folder, len(migrationFilenames(d.config))))
+	}
+
+	if err := requireTrackingTable(d); err != nil {
+		report("fail", "tracking table", err.Error())
+	} else {
+		report("ok", "tracking table", d.config.trackingTableName())
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("doctor found %d problems", problems)
+	}
+
+	return nil
+}
+
+// warnNonTransactionalDDL warns before migrating with a driver that can't
+// roll back schema changes.
+func warnNonTransactionalDDL(d *Dbmig) {
+	if d.dialect().transactionalDDL {
+		return
+	}
+
+	d.logf("Warning: %s doesn't support transactional DDL, a migration failing halfway leaves the database partially migrated. Prefer small migrations with one schema change each", d.dialect().driver)
+}
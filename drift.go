@@ -0,0 +1,151 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// schemaColumns maps the tables of a schema to the definitions of their
+// columns, by column name.
+type schemaColumns map[string]map[string]string
+
+func liveSchemaColumns(tables []*schemaTable) schemaColumns {
+	result := schemaColumns{}
+	for _, t := range tables {
+		result[t.Name] = map[string]string{}
+		for _, c := range t.Columns {
+			result[t.Name][c.Name] = c.String()
+		}
+	}
+
+	return result
+}
+
+// parseSchemaSnapshot reads the tables and columns back from a dump-schema
+// file. Constraints and indexes are left out.
+func parseSchemaSnapshot(data string) (schemaColumns, error) {
+	result := schemaColumns{}
+	var table map[string]string
+	for i, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "CREATE TABLE "):
+			name := strings.TrimSuffix(strings.TrimPrefix(line, "CREATE TABLE "), " (")
+			table = map[string]string{}
+			result[name] = table
+		case line == ");":
+			table = nil
+		case table != nil && strings.HasPrefix(line, "\t") && !strings.HasPrefix(line, "\tCONSTRAINT "):
+			def := strings.TrimSuffix(strings.TrimPrefix(line, "\t"), ",")
+			fields := strings.Fields(def)
+			if len(fields) == 0 {
+				return nil, fmt.Errorf("line %d: empty column definition", i+1)
+			}
+			table[fields[0]] = def
+		}
+	}
+
+	return result, nil
+}
+
+// schemaDrift lists the differences of live from snapshot, table by table.
+func schemaDrift(snapshot schemaColumns, live schemaColumns) []string {
+	names := make([]string, 0, len(snapshot)+len(live))
+	for name := range snapshot {
+		names = append(names, name)
+	}
+	for name := range live {
+		if _, ok := snapshot[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	diffs := make([]string, 0)
+	for _, name := range names {
+		want, inSnapshot := snapshot[name]
+		got, inLive := live[name]
+		switch {
+		case !inLive:
+			diffs = append(diffs, fmt.Sprintf("table %s is missing", name))
+			continue
+		case !inSnapshot:
+			diffs = append(diffs, fmt.Sprintf("table %s was added", name))
+			continue
+		}
+
+		columns := make([]string, 0, len(want)+len(got))
+		for col := range want {
+			columns = append(columns, col)
+		}
+		for col := range got {
+			if _, ok := want[col]; !ok {
+				columns = append(columns, col)
+			}
+		}
+		sort.Strings(columns)
+
+		for _, col := range columns {
+			wantDef, inSnapshot := want[col]
+			gotDef, inLive := got[col]
+			switch {
+			case !inLive:
+				diffs = append(diffs, fmt.Sprintf("column %s.%s is missing", name, col))
+			case !inSnapshot:
+				diffs = append(diffs, fmt.Sprintf("column %s.%s was added", name, col))
+			case wantDef != gotDef:
+				diffs = append(diffs, fmt.Sprintf("column %s.%s changed from %q to %q", name, col, wantDef, gotDef))
+			}
+		}
+	}
+
+	return diffs
+}
+
+// Drift compares the live schema with a dump-schema snapshot and fails when
+// tables or columns were changed outside of migrations.
+func (d *Dbmig) Drift(args []string) error {
+	if len(args) == 0 || args[0] != "drift" {
+		return fmt.Errorf("Invalid call %v", args)
+	}
+
+	var snapshotFile string
+	fs := flag.NewFlagSet("drift", flag.ContinueOnError)
+	fs.StringVar(&snapshotFile, "snapshot", "schema.sql", "Compare with the dump-schema snapshot <file>")
+
+	if _, err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+
+	if !d.config.isPostgres() {
+		return fmt.Errorf("drift is only supported on postgres")
+	}
+
+	data, err := ioutil.ReadFile(snapshotFile)
+	if err != nil {
+		return fmt.Errorf("Cannot read the schema snapshot: %v", err)
+	}
+	snapshot, err := parseSchemaSnapshot(string(data))
+	if err != nil {
+		return fmt.Errorf("Invalid schema snapshot %s: %v", snapshotFile, err)
+	}
+
+	tables, err := dumpSchema(d)
+	if err != nil {
+		return err
+	}
+
+	diffs := schemaDrift(snapshot, liveSchemaColumns(tables))
+	if len(diffs) == 0 {
+		d.printf("No drift, the %d tables match %s\n", len(tables), snapshotFile)
+		return nil
+	}
+
+	for _, diff := range diffs {
+		d.printf("  %s\n", diff)
+	}
+
+	return fmt.Errorf("Schema drifted from %s: %d differences", snapshotFile, len(diffs))
+}
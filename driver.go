@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	_ "github.com/jackc/pgx/v4/stdlib"
+	"github.com/lib/pq"
+)
+
+// dialect describes what differs between the databases dbmi can migrate.
+type dialect struct {
+	// driver is the database/sql driver name passed to sql.Open.
+	driver string
+	// createTrackingTable creates the tracking table named by %s with its
+	// original columns; later columns are added from trackingColumns.
+	createTrackingTable string
+	// numberedPlaceholders is true when the driver understands $1, $2...
+	// and false when it needs ?.
+	numberedPlaceholders bool
+	// returning is true when INSERT and DELETE support RETURNING.
+	returning bool
+	// schemas is true when db_schema and -schema are supported.
+	schemas bool
+	// undefinedTable reports whether err means a table doesn't exist.
+	undefinedTable func(err error) bool
+	// tableExists returns a catalog query, and its arguments, telling whether
+	// the tracking table exists.
+	tableExists func(c *Config) (string, []interface{})
+	// quoteIdent quotes a table, column or schema name.
+	quoteIdent func(name string) string
+	// resetSequence moves the id sequence of the table named by %s past the
+	// ids inserted explicitly, or is empty when the driver does so itself.
+	resetSequence string
+	// transactionalDDL is true when schema changes are rolled back with the
+	// transaction, so a failed migration leaves nothing behind.
+	transactionalDDL bool
+	// lockStrategy is how concurrent runs are kept apart by default,
+	// lockAdvisory or lockTable.
+	lockStrategy string
+	// serverVersion is a query returning the version of the server.
+	serverVersion string
+	// secondsSince is an expression of the whole seconds elapsed since the
+	// timestamp column %s, in the time zone CURRENT_TIMESTAMP defaults use.
+	secondsSince string
+}
+
+const defaultDriver string = "postgres"
+
+var dialects = map[string]*dialect{}
+
+func init() {
+	dialects["postgres"] = &dialect{
+		driver: "postgres",
+		createTrackingTable: `CREATE TABLE IF NOT EXISTS %s (
+		id SERIAL PRIMARY KEY,
+		name VARCHAR(256) NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`,
+		numberedPlaceholders: true,
+		returning:            true,
+		schemas:              true,
+		undefinedTable: func(err error) bool {
+			return sqlState(err) == "42P01"
+		},
+		tableExists: func(c *Config) (string, []interface{}) {
+			return `SELECT to_regclass($1) IS NOT NULL`, []interface{}{c.trackingTable()}
+		},
+		quoteIdent:       pq.QuoteIdentifier,
+		resetSequence:    `SELECT setval(pg_get_serial_sequence('%[1]s', 'id'), COALESCE(MAX(id), 0) + 1, false) FROM %[1]s`,
+		transactionalDDL: true,
+		lockStrategy:     lockAdvisory,
+		serverVersion:    `SELECT version()`,
+		secondsSince:     `CAST(EXTRACT(EPOCH FROM LOCALTIMESTAMP - %s) AS BIGINT)`,
+	}
+
+	// pgx speaks the same SQL as lib/pq, only the database/sql driver differs.
+	pgx := *dialects["postgres"]
+	pgx.driver = "pgx"
+	dialects["pgx"] = &pgx
+}
+
+// isPostgres reports whether the configured driver talks to Postgres.
+func (c *Config) isPostgres() bool {
+	return c.driver() == "postgres" || c.driver() == "pgx"
+}
+
+// quoteDoubleQuotes quotes an identifier the SQL standard way.
+func quoteDoubleQuotes(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+func driverNames() []string {
+	names := make([]string, 0, len(dialects))
+	for name := range dialects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// schemeDrivers are the drivers connection strings select by their scheme
+// when db_driver isn't set.
+var schemeDrivers = map[string]string{
+	"postgres":   "postgres",
+	"postgresql": "postgres",
+	"mysql":      "mysql",
+	"sqlite":     "sqlite3",
+	"sqlite3":    "sqlite3",
+	"file":       "sqlite3",
+}
+
+// connectionScheme returns the lowercased URL scheme of dsn, if it has one.
+func connectionScheme(dsn string) string {
+	i := strings.Index(dsn, ":")
+	if i <= 0 || strings.ContainsAny(dsn[:i], " =") {
+		return ""
+	}
+
+	return strings.ToLower(dsn[:i])
+}
+
+// driver returns db_driver, or the driver the scheme of the connection
+// string calls for, or the default.
+func (c *Config) driver() string {
+	if c.Driver != "" {
+		return c.Driver
+	}
+	if driver, ok := schemeDrivers[connectionScheme(c.ConnectionString)]; ok {
+		return driver
+	}
+
+	return defaultDriver
+}
+
+// dataSourceName returns the connection string as the driver takes it.
+// go-sqlite3 takes file names and file: URIs, not sqlite:// URLs.
+func (c *Config) dataSourceName() string {
+	scheme := connectionScheme(c.ConnectionString)
+	if c.driver() == "sqlite3" && (scheme == "sqlite" || scheme == "sqlite3") {
+		return strings.TrimPrefix(c.ConnectionString[len(scheme)+1:], "//")
+	}
+
+	return c.ConnectionString
+}
+
+func (c *Config) dialect() *dialect {
+	return dialects[c.driver()]
+}
+
+func (d *Dbmig) dialect() *dialect {
+	return d.config.dialect()
+}
+
+var placeholderRe = regexp.MustCompile(`\$\d+`)
+
+// rebind rewrites the $1-style placeholders used throughout dbmi into the
+// style of the configured driver.
+func (d *Dbmig) rebind(query string) string {
+	if d.dialect().numberedPlaceholders {
+		return query
+	}
+
+	return placeholderRe.ReplaceAllString(query, "?")
+}
+
+// supportsReturning reports whether INSERT and DELETE can have a RETURNING
+// clause: the driver supports it and db_no_returning doesn't turn it off, for
+// Postgres compatible databases without it.
+func (d *Dbmig) supportsReturning() bool {
+	return d.dialect().returning && !d.config.NoReturning
+}
+
+// returning appends a RETURNING clause when the database supports it.
+func (d *Dbmig) returning(stmt string, columns ...string) string {
+	if !d.supportsReturning() {
+		return stmt
+	}
+
+	return stmt + " RETURNING " + strings.Join(columns, ", ")
+}
+
+func validateDriver(c *Config) error {
+	dialect := c.dialect()
+	if dialect == nil && c.Driver == "" {
+		return fmt.Errorf("The connection string calls for the %s driver, this build supports: %s", c.driver(), strings.Join(driverNames(), ", "))
+	}
+	if dialect == nil {
+		return fmt.Errorf("Unknown db_driver %q, this build supports: %s", c.Driver, strings.Join(driverNames(), ", "))
+	}
+
+	if c.Schema != "" && !dialect.schemas {
+		return fmt.Errorf("db_driver %s doesn't support db_schema", c.driver())
+	}
+
+	return nil
+}
+
+// Drivers lists the drivers compiled into this build.
+func (d *Dbmig) Drivers(args []string) error {
+	if len(args) == 0 || args[0] != "drivers" {
+		return fmt.Errorf("Invalid call %v", args)
+	}
+
+	for _, name := range driverNames() {
+		marker := " "
+		if name == d.config.driver() {
+			marker = "*"
+		}
+		d.printf("%s %s\n", marker, name)
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+//go:build sqlite
+// +build sqlite
+
+package main
+
+import (
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SQLite support needs cgo, so it is only compiled in with -tags sqlite.
+func init() {
+	dialects["sqlite3"] = &dialect{
+		driver: "sqlite3",
+		createTrackingTable: `CREATE TABLE IF NOT EXISTS %s (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name VARCHAR(256) NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`,
+		numberedPlaceholders: false,
+		// RETURNING only exists since SQLite 3.35.
+		returning: false,
+		schemas:   false,
+		undefinedTable: func(err error) bool {
+			return strings.Contains(err.Error(), "no such table")
+		},
+		tableExists: func(c *Config) (string, []interface{}) {
+			return `SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = $1`, []interface{}{c.Tablename}
+		},
+		quoteIdent:       quoteDoubleQuotes,
+		transactionalDDL: true,
+		lockStrategy:     lockTable,
+		serverVersion:    `SELECT 'SQLite ' || sqlite_version()`,
+		secondsSince:     `CAST(strftime('%%s', 'now') AS INTEGER) - CAST(strftime('%%s', %s) AS INTEGER)`,
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/lib/pq"
+)
+
+// ConfigError lists every problem found in a config, so they can all be
+// fixed at once rather than one run at a time.
+type ConfigError struct {
+	// File is the config file, empty for a config built in code.
+	File     string
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	if len(e.Problems) == 1 {
+		return e.Problems[0]
+	}
+
+	what := "The config"
+	if e.File != "" {
+		what = fmt.Sprintf("Config file %s", e.File)
+	}
+
+	return fmt.Sprintf("%s has %d problems:\n  %s", what, len(e.Problems), strings.Join(e.Problems, "\n  "))
+}
+
+// add records err as a problem, unless it is nil.
+func (e *ConfigError) add(err error) {
+	if err != nil {
+		e.Problems = append(e.Problems, err.Error())
+	}
+}
+
+// orNil returns e when it has problems, and nil otherwise.
+func (e *ConfigError) orNil() error {
+	if len(e.Problems) == 0 {
+		return nil
+	}
+
+	return e
+}
+
+// NotInitializedError means the tracking table doesn't exist, so init
+// hasn't run against the database yet.
+type NotInitializedError struct {
+	Table string
+	// Legacy is set when the table of the old default name exists instead.
+	Legacy string
+}
+
+func (e *NotInitializedError) Error() string {
+	if e.Legacy != "" {
+		return fmt.Sprintf("Tracking table %s not found, but %s is, the default of older versions of %s: to keep using it, set \"db_dbmi_tablename\": %q", e.Table, e.Legacy, programName, e.Legacy)
+	}
+
+	return fmt.Sprintf("Tracking table %s not found; run `%s init`", e.Table, programName)
+}
+
+// migrationError describes a statement of a migration that failed.
+type migrationError struct {
+	Name      string
+	Direction string
+	Statement string
+	// Shown is the statement as printed in the error, see db_log_sql.
+	Shown string
+	// Index is the 1-based number of the failed statement within its
+	// section, when statements run one by one, and Count their number, when
+	// known.
+	Index int
+	Count int
+	// Verbose shows the lines around the error position, see
+	// --verbose-errors.
+	Verbose bool
+	Err     error
+}
+
+func (e *migrationError) Unwrap() error {
+	return e.Err
+}
+
+func (e *migrationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Migration %s (%s) failed", e.Name, e.Direction)
+	if e.Index > 0 {
+		fmt.Fprintf(&b, " at statement %d", e.Index)
+		if e.Count > 0 {
+			fmt.Fprintf(&b, " of %d", e.Count)
+		}
+	}
+	fmt.Fprintf(&b, ": %v", e.Err)
+
+	if fields, ok := postgresError(e.Err); ok {
+		if fields.Position != "" {
+			fmt.Fprintf(&b, "\n  position: %s", fields.Position)
+			if line, ok := positionLine(e.Statement, fields.Position); ok {
+				fmt.Fprintf(&b, " (line %d of the %s section)", line, e.Direction)
+			}
+			// The context window would bypass db_log_sql otherwise.
+			if e.Verbose && e.Shown == e.Statement {
+				if window, ok := contextWindow(e.Statement, fields.Position, contextLines); ok {
+					fmt.Fprintf(&b, "\n%s", window)
+				}
+			}
+		}
+		if fields.Detail != "" {
+			fmt.Fprintf(&b, "\n  detail: %s", fields.Detail)
+		}
+		if fields.Hint != "" {
+			fmt.Fprintf(&b, "\n  hint: %s", fields.Hint)
+		}
+	}
+
+	if e.Shown != "" {
+		fmt.Fprintf(&b, "\n  statement:\n%s", strings.TrimSpace(e.Shown))
+	}
+
+	return b.String()
+}
+
+// positionLine converts a 1-based character position in stmt, as reported by
+// Postgres, to a 1-based line number.
+func positionLine(stmt string, position string) (int, bool) {
+	pos, err := strconv.Atoi(position)
+	if err != nil || pos < 1 {
+		return 0, false
+	}
+
+	runes := []rune(stmt)
+	if pos > len(runes) {
+		return 0, false
+	}
+
+	return strings.Count(string(runes[:pos-1]), "\n") + 1, true
+}
+
+// contextLines is how many lines before and after the error position
+// --verbose-errors shows.
+const contextLines int = 3
+
+// contextWindow shows the lines of stmt around a 1-based character position,
+// numbered, with a caret under the position.
+func contextWindow(stmt string, position string, radius int) (string, bool) {
+	pos, err := strconv.Atoi(position)
+	if err != nil || pos < 1 {
+		return "", false
+	}
+
+	runes := []rune(stmt)
+	if pos > len(runes) {
+		return "", false
+	}
+
+	lines := strings.Split(stmt, "\n")
+	before := string(runes[:pos-1])
+	line := strings.Count(before, "\n")
+	column := []rune(before[strings.LastIndex(before, "\n")+1:])
+
+	first, last := line-radius, line+radius
+	if first < 0 {
+		first = 0
+	}
+	if last > len(lines)-1 {
+		last = len(lines) - 1
+	}
+
+	width := len(strconv.Itoa(last + 1))
+	var b strings.Builder
+	for i := first; i <= last; i++ {
+		fmt.Fprintf(&b, "  %*d | %s\n", width, i+1, lines[i])
+		if i == line {
+			// Keep tabs so the caret lines up with the text above.
+			indent := strings.Map(func(r rune) rune {
+				if r == '\t' {
+					return r
+				}
+				return ' '
+			}, string(column))
+			fmt.Fprintf(&b, "  %*s | %s^\n", width, "", indent)
+		}
+	}
+
+	return strings.TrimRight(b.String(), "\n"), true
+}
+
+// postgresErrorFields are the parts of a Postgres error report dbmi shows,
+// the same for lib/pq and pgx.
+type postgresErrorFields struct {
+	Code     string
+	Position string
+	Detail   string
+	Hint     string
+}
+
+func postgresError(err error) (postgresErrorFields, bool) {
+	switch e := err.(type) {
+	case *pq.Error:
+		return postgresErrorFields{Code: string(e.Code), Position: e.Position, Detail: e.Detail, Hint: e.Hint}, true
+	case *pgconn.PgError:
+		position := ""
+		if e.Position > 0 {
+			position = strconv.Itoa(int(e.Position))
+		}
+		return postgresErrorFields{Code: e.Code, Position: position, Detail: e.Detail, Hint: e.Hint}, true
+	}
+
+	return postgresErrorFields{}, false
+}
+
+// sqlState returns the SQLSTATE code of a Postgres error, or "".
+func sqlState(err error) string {
+	fields, _ := postgresError(err)
+	return fields.Code
+}
@@ -0,0 +1,38 @@
+//go:build sqlite
+// +build sqlite
+
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNotInitializedPointsAtLegacyTable(t *testing.T) {
+	d := newTestDbmig(t, nil)
+	if _, err := d.db.Exec(`ALTER TABLE db_migrations RENAME TO migrations`); err != nil {
+		t.Fatal(err)
+	}
+
+	err := runCommand(d, []string{"status"})
+	var notInitialized *NotInitializedError
+	if !errors.As(err, &notInitialized) {
+		t.Fatalf("status returned %v, want a *NotInitializedError", err)
+	}
+	if notInitialized.Legacy == "" || !strings.Contains(err.Error(), `"db_dbmi_tablename": "migrations"`) {
+		t.Errorf("error doesn't point at the legacy table: %v", err)
+	}
+
+	d.config.Tablename = "migrations"
+	mustRun(t, d, "status")
+}
+
+func TestInitFailIfExists(t *testing.T) {
+	d := newTestDbmig(t, nil)
+
+	mustRun(t, d, "init")
+	if err := runCommand(d, []string{"init", "--fail-if-exists"}); err == nil {
+		t.Error("init --fail-if-exists succeeded on an existing tracking table")
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const exampleMigration string = `-- Everything above the separator is run by ` + "`dbmi migrate up`" + `.
+CREATE TABLE example_items (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(64) NOT NULL
+);
+
+/*DOWN*/
+-- Everything below it is run by ` + "`dbmi migrate down`" + `, and must undo the above.
+DROP TABLE example_items;
+`
+
+const exampleReadme string = `# Migrations
+
+Each file is a migration named ` + "`<unix timestamp>_<name>.sql`" + `, applied in
+timestamp order. Create one with ` + "`dbmi new <name>`" + `.
+
+A migration has two sections separated by a ` + "`/*DOWN*/`" + ` line: the SQL that
+applies the change, and the SQL that reverts it. See the example migration,
+then remove it or migrate it down before writing your own.
+
+    dbmi migrate up      # apply pending migrations
+    dbmi migrate down    # revert the last one
+    dbmi status          # list them with their state
+`
+
+// scaffoldExample writes an example migration and a README explaining the
+// file format into the migrations folder, unless it already has migrations.
+func scaffoldExample(d *Dbmig) error {
+	c := d.config
+	if names := migrationFilenames(c); len(names) > 0 {
+		d.printf("Not creating an example, %s already has %d migrations\n", c.Folder, len(names))
+		return nil
+	}
+
+	name := fmt.Sprintf("%d_create_example_items.sql", time.Now().Unix())
+	fpath := filepath.Join(c.Folder, name)
+	if err := ioutil.WriteFile(fpath, []byte(exampleMigration), 0644); err != nil {
+		return err
+	}
+	d.printf("Example migration created: %s\n", fpath)
+
+	readme := filepath.Join(c.Folder, "README.md")
+	if _, err := os.Stat(readme); err == nil {
+		return nil
+	}
+	if err := ioutil.WriteFile(readme, []byte(exampleReadme), 0644); err != nil {
+		return err
+	}
+	d.printf("README created: %s\n", readme)
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Exec runs a maintenance script, like ANALYZE or REINDEX, over the
+// configured connection. It is not a migration: nothing is recorded, and its
+// statements run one by one outside of a transaction, as some of them, like
+// VACUUM, refuse to run in one.
+func (d *Dbmig) Exec(args []string) error {
+	if len(args) == 0 || args[0] != "exec" {
+		return fmt.Errorf("Invalid call %v", args)
+	}
+
+	fs := flag.NewFlagSet("exec", flag.ContinueOnError)
+	positional, err := parseFlags(fs, args[1:])
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("Usage: %s exec <file.sql|->", programName)
+	}
+	fname := positional[0]
+
+	var src io.Reader = os.Stdin
+	if fname == "-" {
+		fname = "stdin"
+	} else {
+		f, err := os.Open(fname)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		src = f
+	}
+
+	data, err := readMigration(src)
+	if err != nil {
+		return err
+	}
+	if data, err = renderTemplate(d.config, fname, data); err != nil {
+		return err
+	}
+
+	timeout := d.config.statementTimeout()
+	if values := parseDirectives(data)["timeout"]; len(values) > 0 {
+		if timeout, err = parseTimeout(values[len(values)-1]); err != nil {
+			return err
+		}
+	}
+
+	stmts, err := splitStatements(data)
+	if err != nil {
+		return fmt.Errorf("Splitting %s into statements: %v", fname, err)
+	}
+
+	for i, stmt := range stmts {
+		d.logf("Executing %s (%d of %d)\n %s\n", fname, i+1, len(stmts), d.config.loggedStatement(stmt))
+
+		ctx, cancel := context.WithTimeout(d.context(), timeout)
+		_, err := d.db.ExecContext(ctx, stmt)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("%s failed at statement %d of %d: %v\n  statement:\n%s", fname, i+1, len(stmts), err, d.config.loggedStatement(stmt))
+		}
+	}
+
+	d.printf("%s\t%s (%d statements)\n", paint(colorStdout, colorGreen, "executed"), fname, len(stmts))
+
+	return nil
+}
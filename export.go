@@ -0,0 +1,129 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// pendingMigrations returns the migrations not applied yet, in the order
+// they would be applied, along with the applied ones.
+func pendingMigrations(d *Dbmig) (pending []string, applied []string, err error) {
+	applied, err = appliedMigrations(d.context(), d, -1, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files := migrationFilenames(d.config)
+	if err := checkBaseNameRows(applied, files); err != nil {
+		return nil, nil, err
+	}
+
+	available := withoutRepeatables(d, files)
+	pending, err = orderMigrations(d, diffOf(available, applied), applied)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pending, applied, nil
+}
+
+// Export writes the SQL that `migrate` would run to stdout without running
+// it: the up sections of pending migrations, or the down sections of applied
+// migrations newest first. With --to the script stops after that migration.
+func (d *Dbmig) Export(args []string) error {
+	if len(args) == 0 || args[0] != "export" {
+		return fmt.Errorf("Invalid call %v", args)
+	}
+
+	var to string
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.StringVar(&to, "to", "", "Stop after migration <filename>")
+
+	positional, err := parseFlags(fs, args[1:])
+	if err != nil {
+		return err
+	}
+
+	if len(positional) != 1 || (positional[0] != "up" && positional[0] != "down") {
+		return fmt.Errorf("Usage: %s export <up|down> [--to <filename>]", programName)
+	}
+	direction := positional[0]
+
+	var names []string
+	state := "pending"
+	if direction == "up" {
+		names, _, err = pendingMigrations(d)
+	} else {
+		state = "applied"
+		var applied []string
+		applied, err = appliedMigrations(d.context(), d, -1, false)
+		names = reversed(withoutRepeatables(d, applied))
+	}
+	if err != nil {
+		return err
+	}
+
+	if to != "" {
+		i := indexOf(names, to)
+		if i < 0 {
+			return fmt.Errorf("Migration %s is not %s", to, state)
+		}
+		names = names[:i+1]
+	}
+
+	for _, fname := range names {
+		if isGoMigration(fname) {
+			d.printf("-- migration: %s (%s) is written in Go and can't be exported\n\n", fname, direction)
+			continue
+		}
+
+		data, err := readMigrationFile(d, fname)
+		if err != nil {
+			return err
+		}
+
+		m, ok := parseMigration(fname, data)
+		if !ok {
+			return fmt.Errorf("Migration %s has no %s separator", fname, migrationSeparator)
+		}
+
+		stmt := m.Up
+		if direction == "down" {
+			if m.forwardOnly() {
+				return fmt.Errorf("Migration %s is forward-only and has no down section", fname)
+			}
+			stmt = m.Down
+		}
+
+		target, err := sectionCopyTarget(stmt)
+		if err != nil {
+			return fmt.Errorf("%s: %v", fname, err)
+		}
+		if target != nil {
+			stmt = target.psqlCopy(stmt)
+		}
+		d.printf("-- migration: %s (%s)\n%s\n\n", fname, direction, strings.TrimSpace(stmt))
+	}
+
+	return nil
+}
+
+func reversed(a []string) []string {
+	result := make([]string, len(a))
+	for i, s := range a {
+		result[len(a)-1-i] = s
+	}
+
+	return result
+}
+
+func indexOf(a []string, s string) int {
+	for i, v := range a {
+		if v == s {
+			return i
+		}
+	}
+
+	return -1
+}
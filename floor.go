@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// atOrBeforeFloor reports whether fname is min_version or sorts before it.
+func atOrBeforeFloor(c *Config, fname string) bool {
+	if c.MinVersion == "" {
+		return false
+	}
+	if fname == c.MinVersion {
+		return true
+	}
+
+	names := []string{c.MinVersion, fname}
+	sortMigrations(c, names)
+
+	return names[0] == fname
+}
+
+// guardMinVersion refuses to migrate down names when one of them is at or
+// before min_version, the floor of migrations that may never be rolled back.
+func guardMinVersion(d *Dbmig, names []string) error {
+	protected := make([]string, 0)
+	for _, fname := range names {
+		if atOrBeforeFloor(d.config, fname) {
+			protected = append(protected, fname)
+		}
+	}
+
+	if len(protected) > 0 {
+		return fmt.Errorf("Refusing to migrate down %s: min_version %s protects it and the migrations before it from being rolled back", strings.Join(protected, ", "), d.config.MinVersion)
+	}
+
+	return nil
+}
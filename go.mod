@@ -2,4 +2,10 @@ module mirtidi.com/dbmi
 
 go 1.15
 
-require github.com/lib/pq v1.9.0
+require (
+	github.com/jackc/pgconn v1.8.0
+	github.com/jackc/pgx/v4 v4.10.1
+	github.com/lib/pq v1.9.0
+	github.com/mattn/go-sqlite3 v1.14.8
+	golang.org/x/term v0.0.0-20210220032956-6a3ed077a48d
+)
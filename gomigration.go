@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// MigrationFunc is one direction of a migration written in Go. It runs in
+// the same transaction that records the migration in the tracking table,
+// and ctx ends at db_migration_timeout or --timeout-per-migration, when set.
+type MigrationFunc func(ctx context.Context, tx *sql.Tx) error
+
+type goMigration struct {
+	up   MigrationFunc
+	down MigrationFunc
+}
+
+var goMigrations = map[string]goMigration{}
+
+// RegisterMigration adds a migration written in Go, for changes that are
+// awkward in SQL such as re-encrypting a column. The name follows the
+// <timestamp>_<name> convention of migration files, e.g.
+// "1609459200_reencrypt_tokens", and is what the tracking table records.
+// Go migrations are applied interleaved with SQL migrations by timestamp.
+//
+// dbmi is a command, in package main, which can't be imported: register Go
+// migrations from an init function in a file added to this package, and
+// build dbmi with it:
+//
+//	func init() {
+//		RegisterMigration("1609459200_reencrypt_tokens", reencrypt, decrypt)
+//	}
+func RegisterMigration(name string, up MigrationFunc, down MigrationFunc) {
+	if _, ok := migrationTime(name); !ok {
+		panic(fmt.Sprintf("dbmi: Go migration %q must be named <timestamp>_<name>", name))
+	}
+	if _, ok := goMigrations[name]; ok {
+		panic(fmt.Sprintf("dbmi: Go migration %q registered twice", name))
+	}
+
+	goMigrations[name] = goMigration{up: up, down: down}
+}
+
+func isGoMigration(name string) bool {
+	_, ok := goMigrations[name]
+	return ok
+}
+
+func goMigrationNames() []string {
+	names := make([]string, 0, len(goMigrations))
+	for name := range goMigrations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func applyGoMigration(d *Dbmig, name string, direction string, gm goMigration) error {
+	fn := gm.up
+	if direction == "down" {
+		fn = gm.down
+	}
+	if fn == nil {
+		return fmt.Errorf("Go migration %s has no %s function", name, direction)
+	}
+
+	d.logf("Applying Go migration: %s (%s)\n", name, direction)
+
+	m := &migration{Name: name}
+	return runMigrationTx(d, m, direction, func(ctx context.Context, tx *sql.Tx, timeout time.Duration) error {
+		if err := fn(ctx, tx); err != nil {
+			return &migrationError{Name: name, Direction: direction, Err: err}
+		}
+		return nil
+	}, func() string { return "" })
+}
@@ -0,0 +1,55 @@
+//go:build sqlite
+// +build sqlite
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+// registerTestMigration registers a Go migration for the length of the test.
+func registerTestMigration(t *testing.T, name string, up MigrationFunc) {
+	RegisterMigration(name, up, nil)
+	t.Cleanup(func() { delete(goMigrations, name) })
+}
+
+func TestGoMigrationOutlivesStatementTimeout(t *testing.T) {
+	registerTestMigration(t, "1600000000_slow", func(ctx context.Context, tx *sql.Tx) error {
+		time.Sleep(150 * time.Millisecond)
+		_, err := tx.ExecContext(ctx, "CREATE TABLE slow (id INTEGER)")
+		return err
+	})
+	d := newTestDbmig(t, nil)
+	d.config.StatementTimeout = "50ms"
+
+	if err := applyMigration(context.Background(), d, "1600000000_slow", "up"); err != nil {
+		t.Fatalf("Go migration running longer than the statement timeout failed: %v", err)
+	}
+}
+
+func TestGoMigrationGetsMigrationTimeout(t *testing.T) {
+	registerTestMigration(t, "1600000000_stuck", func(ctx context.Context, tx *sql.Tx) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+			return nil
+		}
+	})
+	d := newTestDbmig(t, nil)
+	d.opts.migrationTimeout = 50 * time.Millisecond
+
+	start := time.Now()
+	if err := applyMigration(context.Background(), d, "1600000000_stuck", "up"); err == nil {
+		t.Fatal("Go migration outliving its migration timeout succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Go migration was stopped after %s", elapsed)
+	}
+	if got := appliedNames(t, d); len(got) != 0 {
+		t.Errorf("applied %v after the timeout", got)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// tablePrivileges are the privileges accepted in db_table_grants.
+var tablePrivileges = map[string]bool{
+	"SELECT": true, "INSERT": true, "UPDATE": true, "DELETE": true,
+	"TRUNCATE": true, "REFERENCES": true, "TRIGGER": true, "ALL": true,
+}
+
+// validateTableAccess checks db_table_owner and db_table_grants, which are
+// spliced into SQL and so must be plain identifiers and known privileges.
+func validateTableAccess(c *Config) error {
+	if c.TableOwner == "" && len(c.TableGrants) == 0 {
+		return nil
+	}
+	if !c.isPostgres() {
+		return fmt.Errorf("db_driver %s doesn't support db_table_owner and db_table_grants", c.driver())
+	}
+
+	if c.TableOwner != "" && !identifierRe.MatchString(c.TableOwner) {
+		return fmt.Errorf("db_table_owner %q is not a valid role name", c.TableOwner)
+	}
+
+	for role, privileges := range c.TableGrants {
+		if !identifierRe.MatchString(role) {
+			return fmt.Errorf("db_table_grants: %q is not a valid role name", role)
+		}
+		if _, err := parsePrivileges(privileges); err != nil {
+			return fmt.Errorf("db_table_grants for %s: %v", role, err)
+		}
+	}
+
+	return nil
+}
+
+// parsePrivileges parses a comma separated list of privileges like
+// "SELECT, INSERT".
+func parsePrivileges(s string) ([]string, error) {
+	privileges := make([]string, 0)
+	for _, p := range strings.Split(s, ",") {
+		p = strings.ToUpper(strings.TrimSpace(p))
+		if !tablePrivileges[p] {
+			return nil, fmt.Errorf("Invalid privilege %q", p)
+		}
+		privileges = append(privileges, p)
+	}
+
+	return privileges, nil
+}
+
+// setTableAccess gives the tracking table to db_table_owner and grants
+// db_table_grants on it, after init created it. It does nothing when neither
+// is configured.
+func setTableAccess(d *Dbmig) error {
+	ctx, cancel := d.statementContext()
+	defer cancel()
+
+	if owner := d.config.TableOwner; owner != "" {
+		query := fmt.Sprintf("ALTER TABLE %s OWNER TO %s", d.config.trackingTable(), owner)
+		if _, err := d.db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("Making %s the owner of tracking table '%s': %v", owner, d.config.trackingTableName(), err)
+		}
+		d.printf("Tracking table '%s' is owned by %s\n", d.config.trackingTableName(), owner)
+	}
+
+	roles := make([]string, 0, len(d.config.TableGrants))
+	for role := range d.config.TableGrants {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+
+	for _, role := range roles {
+		privileges, err := parsePrivileges(d.config.TableGrants[role])
+		if err != nil {
+			return err
+		}
+		query := fmt.Sprintf("GRANT %s ON %s TO %s", strings.Join(privileges, ", "), d.config.trackingTable(), role)
+		if _, err := d.db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("Granting %s on tracking table '%s' to %s: %v", strings.Join(privileges, ", "), d.config.trackingTableName(), role, err)
+		}
+		d.printf("Granted %s on tracking table '%s' to %s\n", strings.Join(privileges, ", "), d.config.trackingTableName(), role)
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// historyRow is a row of the tracking table, by column. It holds all columns,
+// so the ones added by later versions of dbmi show up too.
+type historyRow map[string]interface{}
+
+// trackingHistory returns the rows of the tracking table in applied order,
+// only the latest limit ones when limit is positive and only those applied
+// with migrate --release when release isn't empty.
+func trackingHistory(d *Dbmig, limit int, release string) ([]string, []historyRow, error) {
+	ctx, cancel := d.statementContext()
+	defer cancel()
+
+	query := fmt.Sprintf("SELECT * FROM %s", d.config.trackingTable())
+	var args []interface{}
+	if release != "" {
+		args = append(args, release)
+		query += " WHERE release = $1"
+	}
+	query += " ORDER BY version DESC, id DESC"
+	if limit > 0 {
+		args = append(args, limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := d.db.QueryContext(ctx, d.rebind(query), args...)
+	if err != nil {
+		return nil, nil, trackingTableError(d, err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	history := make([]historyRow, 0)
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, nil, err
+		}
+
+		row := historyRow{}
+		for i, column := range columns {
+			row[column] = historyValue(values[i])
+		}
+		history = append(history, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	// Fetched newest first for the limit.
+	for i, j := 0, len(history)-1; i < j; i, j = i+1, j-1 {
+		history[i], history[j] = history[j], history[i]
+	}
+
+	return columns, history, nil
+}
+
+// historyValue makes a scanned value printable: drivers return text as bytes
+// and times in the zone of the session.
+func historyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	}
+
+	return value
+}
+
+func (d *Dbmig) History(args []string) error {
+	if len(args) == 0 || args[0] != "history" {
+		return fmt.Errorf("Invalid call %v", args)
+	}
+
+	var limit int
+	var asJSON bool
+	var release string
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	fs.IntVar(&limit, "limit", 0, "Only show the latest <n> applied migrations")
+	fs.BoolVar(&asJSON, "json", false, "Print the rows as a JSON array")
+	fs.StringVar(&release, "release", "", "Only show the migrations applied with migrate --release <label>")
+
+	if _, err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+	if limit < 0 {
+		return fmt.Errorf("Invalid --limit %d", limit)
+	}
+
+	columns, history, err := trackingHistory(d, limit, release)
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		out, err := json.MarshalIndent(history, "", "  ")
+		if err != nil {
+			return err
+		}
+		d.println(string(out))
+		return nil
+	}
+
+	d.println(strings.Join(columns, "\t"))
+	for _, row := range history {
+		fields := make([]string, len(columns))
+		for i, column := range columns {
+			if row[column] != nil {
+				fields[i] = fmt.Sprint(row[column])
+			}
+		}
+		d.println(strings.Join(fields, "\t"))
+	}
+
+	return nil
+}
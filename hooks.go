@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// runHook runs a configured hook command with the migration name and
+// direction appended to its arguments. The hook's stderr is included in the
+// returned error when the command fails.
+func runHook(d *Dbmig, hook string, fname string, direction string) error {
+	fields := strings.Fields(hook)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	args := append(fields[1:], fname, direction)
+	cmd := exec.Command(fields[0], args...)
+	cmd.Stdout = d.stdout()
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg != "" {
+			return fmt.Errorf("Hook %q failed for %s (%s): %v: %s", hook, fname, direction, err, msg)
+		}
+		return fmt.Errorf("Hook %q failed for %s (%s): %v", hook, fname, direction, err)
+	}
+
+	if stderr.Len() > 0 {
+		d.logf("Hook %q stderr: %s", hook, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
+
+// runPostRunHook runs db_post_run_hook once after a migrate run applied or
+// reverted the migrations names, all successfully. It receives their number
+// as its last argument and their names on stdin, one per line. A failure is
+// logged, and only returned when db_post_run_hook_fatal is set.
+func runPostRunHook(d *Dbmig, names []string) error {
+	fields := strings.Fields(d.config.PostRunHook)
+	if len(fields) == 0 || len(names) == 0 {
+		return nil
+	}
+
+	args := append(fields[1:], strconv.Itoa(len(names)))
+	cmd := exec.Command(fields[0], args...)
+	cmd.Stdin = strings.NewReader(strings.Join(names, "\n") + "\n")
+	cmd.Stdout = d.stdout()
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("Post-run hook %q failed after %d migrations: %v: %s", d.config.PostRunHook, len(names), err, msg)
+		} else {
+			err = fmt.Errorf("Post-run hook %q failed after %d migrations: %v", d.config.PostRunHook, len(names), err)
+		}
+		if d.config.PostRunHookFatal {
+			return err
+		}
+		d.logf("%s", err)
+		return nil
+	}
+
+	if stderr.Len() > 0 {
+		d.logf("Post-run hook %q stderr: %s", d.config.PostRunHook, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
+
+// runMigration applies a single migration surrounded by the configured pre
+// and post hooks. A failing pre-hook aborts the migration; a failing post-hook
+// is logged and only fails the run when db_post_hook_fatal is set.
+func runMigration(d *Dbmig, fname string, direction string) error {
+	if err := checkConnection(d, fname); err != nil {
+		return err
+	}
+
+	return runMigrationWith(d, fname, direction, func() error {
+		return withRetries(d, fname, func() error {
+			return applyMigration(d.context(), d, fname, direction)
+		})
+	})
+}
+
+// runMigrationWith runs the hooks around apply, which applies the migration
+// fname.
+func runMigrationWith(d *Dbmig, fname string, direction string, apply func() error) error {
+	if d.config.PreHook != "" {
+		if err := runHook(d, d.config.PreHook, fname, direction); err != nil {
+			return err
+		}
+	}
+
+	if err := apply(); err != nil {
+		d.printf("%s\t%s\n", paint(colorStdout, colorRed, "failed"), fname)
+		if !d.dialect().transactionalDDL {
+			d.logf("Warning: %s may be partially applied, check the database before retrying", fname)
+		}
+		return err
+	}
+
+	if direction == "down" {
+		d.printf("%s\t%s\n", paint(colorStdout, colorYellow, "reverted"), fname)
+	} else {
+		d.printf("%s\t%s\n", paint(colorStdout, colorGreen, "applied"), fname)
+	}
+
+	if d.config.PostHook != "" {
+		if err := runHook(d, d.config.PostHook, fname, direction); err != nil {
+			d.logf("%s", err)
+			if d.config.PostHookFatal {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
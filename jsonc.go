@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// stripJSONComments blanks out the // and /* */ comments and the trailing
+// commas of hand-edited JSON, leaving strict JSON alone. Everything removed
+// is replaced by spaces, newlines kept, so error offsets still point into
+// the original.
+func stripJSONComments(data []byte) []byte {
+	out := make([]byte, len(data))
+	copy(out, data)
+
+	blank := func(from, to int) {
+		for i := from; i < to; i++ {
+			if out[i] != '\n' {
+				out[i] = ' '
+			}
+		}
+	}
+
+	inString := false
+	comma := -1
+	for i := 0; i < len(out); i++ {
+		c := out[i]
+
+		if inString {
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+
+		switch {
+		case c == '"':
+			inString = true
+			comma = -1
+		case c == '/' && i+1 < len(out) && out[i+1] == '/':
+			end := bytes.IndexByte(out[i:], '\n')
+			if end < 0 {
+				end = len(out) - i
+			}
+			blank(i, i+end)
+			i += end - 1
+		case c == '/' && i+1 < len(out) && out[i+1] == '*':
+			end := bytes.Index(out[i+2:], []byte("*/"))
+			if end < 0 {
+				// Left for the decoder to report.
+				return out
+			}
+			blank(i, i+2+end+2)
+			i += 2 + end + 1
+		case c == ',':
+			comma = i
+		case c == '}' || c == ']':
+			if comma >= 0 {
+				out[comma] = ' '
+			}
+			comma = -1
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+		default:
+			comma = -1
+		}
+	}
+
+	return out
+}
+
+// decodeConfig decodes a config file that may contain comments and trailing
+// commas into config, reporting where syntax errors are.
+func decodeConfig(data []byte, config *Config) error {
+	data = stripJSONComments(data)
+
+	err := json.Unmarshal(data, config)
+	if e, ok := err.(*json.SyntaxError); ok {
+		line := bytes.Count(data[:e.Offset], []byte("\n")) + 1
+		return fmt.Errorf("line %d: %v", line, err)
+	}
+
+	return err
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// keepaliveInterval returns how often idle connections are pinged during a
+// run, or 0 when keepalive is off. The value is validated when the config is
+// loaded.
+func (c *Config) keepaliveInterval() time.Duration {
+	if c.Keepalive == "" {
+		return 0
+	}
+
+	interval, err := time.ParseDuration(c.Keepalive)
+	if err != nil {
+		return 0
+	}
+
+	return interval
+}
+
+// startKeepalive pings the database every db_keepalive_interval, so proxies
+// that drop idle connections don't cut them between migrations. The
+// returned function stops it.
+func startKeepalive(d *Dbmig) func() {
+	interval := d.config.keepaliveInterval()
+	if interval == 0 {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(d.context())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				pingCtx, cancelPing := context.WithTimeout(ctx, d.config.statementTimeout())
+				if err := d.db.PingContext(pingCtx); err != nil && ctx.Err() == nil {
+					d.logf("Keepalive ping failed: %v", err)
+				}
+				cancelPing()
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func validateKeepalive(c *Config) error {
+	if c.Keepalive == "" {
+		return nil
+	}
+
+	if _, err := parseTimeout(c.Keepalive); err != nil {
+		return fmt.Errorf("db_keepalive_interval: %v", err)
+	}
+
+	return nil
+}
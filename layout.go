@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	formatSeparator string = "separator"
+	formatSplit     string = "split"
+)
+
+// A split migration keeps its sections in 1609459200_create_items.up.sql
+// and 1609459200_create_items.down.sql. It is tracked under the up file.
+const (
+	upSuffix   string = ".up.sql"
+	downSuffix string = ".down.sql"
+)
+
+func isDownFile(fname string) bool {
+	return strings.HasSuffix(fname, downSuffix)
+}
+
+func isSplitMigration(fname string) bool {
+	return strings.HasSuffix(fname, upSuffix)
+}
+
+func downFileFor(fname string) string {
+	return strings.TrimSuffix(fname, upSuffix) + downSuffix
+}
+
+// fileFormat returns the layout of new migrations, db_file_format or a
+// single file with a separator.
+func (c *Config) fileFormat() string {
+	if c.FileFormat == "" {
+		return formatSeparator
+	}
+
+	return c.FileFormat
+}
+
+// validateFileFormat checks the format given by option, db_file_format or
+// new --format.
+func validateFileFormat(option string, format string) error {
+	if format != formatSeparator && format != formatSplit {
+		return fmt.Errorf("Invalid %s %q, use %s or %s", option, format, formatSeparator, formatSplit)
+	}
+
+	return nil
+}
+
+// defaultFolderLayout groups migrations by year and month for new
+// --dir-from-name, when db_folder_layout isn't set.
+const defaultFolderLayout string = "2006/01"
+
+// migrationSubfolder returns the subfolder of the migrations folder that a
+// migration created at t goes in: db_folder_layout applied to t, or the
+// default layout when dated, or none for a flat folder.
+func (c *Config) migrationSubfolder(t time.Time, dated bool) string {
+	layout := c.FolderLayout
+	if layout == "" && !dated {
+		return ""
+	}
+	if layout == "" {
+		layout = defaultFolderLayout
+	}
+
+	return t.UTC().Format(layout)
+}
+
+// validateFolderLayout checks that db_folder_layout is a relative time
+// layout, like 2006/01, within db_max_depth.
+func validateFolderLayout(c *Config) error {
+	if c.FolderLayout == "" {
+		return nil
+	}
+
+	reference := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	if reference.Format(c.FolderLayout) == c.FolderLayout {
+		return fmt.Errorf("db_folder_layout %q has no date in it, use a Go time layout like %s", c.FolderLayout, defaultFolderLayout)
+	}
+	if path.IsAbs(c.FolderLayout) || strings.Contains(c.FolderLayout, "..") {
+		return fmt.Errorf("db_folder_layout %q must stay within the migrations folder", c.FolderLayout)
+	}
+	if depth := strings.Count(path.Clean(c.FolderLayout), "/") + 2; c.MaxDepth > 0 && depth > c.MaxDepth {
+		return fmt.Errorf("db_folder_layout %q puts migrations %d levels deep, deeper than db_max_depth %d", c.FolderLayout, depth, c.MaxDepth)
+	}
+
+	return nil
+}
+
+// migrationFile reads a migration file. The two files of a split migration
+// read as one, with the separator in between.
+type migrationFile struct {
+	io.Reader
+	files []*os.File
+	size  int64
+}
+
+func openMigrationFile(c *Config, fname string) (*migrationFile, error) {
+	names := []string{fname}
+	if isSplitMigration(fname) {
+		names = append(names, downFileFor(fname))
+	}
+
+	mf := &migrationFile{}
+	readers := make([]io.Reader, 0, 3)
+	for i, name := range names {
+		f, err := os.Open(fmt.Sprintf("%s/%s", c.Folder, name))
+		// A split migration without a down file is read as its up section.
+		if i > 0 && os.IsNotExist(err) {
+			break
+		}
+		if err != nil {
+			mf.Close()
+			return nil, err
+		}
+		mf.files = append(mf.files, f)
+
+		info, err := f.Stat()
+		if err != nil {
+			mf.Close()
+			return nil, err
+		}
+		mf.size += info.Size()
+
+		if i > 0 {
+			readers = append(readers, strings.NewReader("\n"+migrationSeparator+"\n"))
+		}
+		readers = append(readers, f)
+	}
+	mf.Reader = io.MultiReader(readers...)
+
+	return mf, nil
+}
+
+func (mf *migrationFile) Close() error {
+	var first error
+	for _, f := range mf.files {
+		if err := f.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"time"
+)
+
+// Migration lock strategies, picked per driver by the dialect and
+// overridable with db_lock_strategy.
+const (
+	lockAdvisory string = "advisory"
+	lockTable    string = "table"
+	lockRow      string = "row"
+)
+
+// lockPollInterval is how often a locked lock table is checked again.
+const lockPollInterval time.Duration = time.Second
+
+func (c *Config) lockStrategy() string {
+	if c.LockStrategy != "" {
+		return c.LockStrategy
+	}
+
+	return c.dialect().lockStrategy
+}
+
+// lockTable returns the quoted name of the table holding the migration lock
+// when the lock table strategy is used.
+func (c *Config) lockTable() string {
+	lockConfig := *c
+	lockConfig.Tablename = c.Tablename + "_lock"
+
+	return lockConfig.trackingTable()
+}
+
+func validateLockStrategy(c *Config) error {
+	switch c.lockStrategy() {
+	case lockTable:
+		return nil
+	case lockAdvisory:
+		if c.isPostgres() {
+			return nil
+		}
+		return fmt.Errorf("db_driver %s doesn't support advisory locks, use db_lock_strategy %q", c.driver(), lockTable)
+	case lockRow:
+		if c.isPostgres() {
+			return nil
+		}
+		return fmt.Errorf("db_driver %s doesn't support SELECT ... FOR UPDATE, use db_lock_strategy %q", c.driver(), lockTable)
+	}
+
+	return fmt.Errorf("Unknown db_lock_strategy %q, expected %q, %q or %q", c.LockStrategy, lockAdvisory, lockTable, lockRow)
+}
+
+// lockFreeCommands only read the tracking table, and never take the
+// migration lock, so they can be polled while another connection migrates.
+// What they show may be gone a moment later.
+var lockFreeCommands = map[string]bool{
+	"status":  true,
+	"current": true,
+	"history": true,
+	"plan":    true,
+	"verify":  true,
+}
+
+// acquireLock takes the migration lock, waiting for another run holding it
+// to finish, and returns the function releasing it.
+func acquireLock(d *Dbmig) (func(), error) {
+	if d.opts.lockFree {
+		return nil, fmt.Errorf("Read-only commands must not take the migration lock")
+	}
+
+	defer d.config.profile.time("lock", "")()
+
+	switch d.config.lockStrategy() {
+	case lockAdvisory:
+		return acquireAdvisoryLock(d)
+	case lockRow:
+		return acquireRowLock(d)
+	}
+
+	return acquireTableLock(d)
+}
+
+// acquireAdvisoryLock takes a Postgres advisory lock keyed on the tracking
+// table and the connection name. Advisory locks belong to a session, so a
+// connection is set aside until the lock is released.
+func acquireAdvisoryLock(d *Dbmig) (func(), error) {
+	conn, err := d.db.Conn(d.context())
+	if err != nil {
+		return nil, err
+	}
+
+	key := d.config.advisoryLockKey()
+	if _, err := conn.ExecContext(d.context(), `SELECT pg_advisory_lock(hashtext($1))`, key); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("Taking the migration lock: %w", err)
+	}
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), d.config.statementTimeout())
+		defer cancel()
+
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock(hashtext($1))`, key); err != nil {
+			d.logf("Releasing the migration lock: %v", err)
+		}
+		conn.Close()
+	}, nil
+}
+
+// acquireTableLock claims the single row of the lock table with an atomic
+// UPDATE, for databases without advisory locks.
+func acquireTableLock(d *Dbmig) (func(), error) {
+	if err := createLockTable(d); err != nil {
+		return nil, err
+	}
+
+	claim := fmt.Sprintf(`UPDATE %s SET locked = true, locked_at = CURRENT_TIMESTAMP WHERE id = 1 AND locked = false`, d.config.lockTable())
+	waiting := false
+	for {
+		ctx, cancel := d.statementContext()
+		res, err := d.db.ExecContext(ctx, claim)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("Taking the migration lock: %w", err)
+		}
+
+		claimed, err := res.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if claimed == 1 {
+			break
+		}
+
+		if !waiting {
+			d.logf("Waiting for the migration lock in %s, held by another run. If none is running, a killed run left it behind: release it with %s unlock", d.config.lockTable(), programName)
+			waiting = true
+		}
+		select {
+		case <-d.context().Done():
+			return nil, fmt.Errorf("Waiting for the migration lock: %w", d.context().Err())
+		case <-time.After(lockPollInterval):
+		}
+	}
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), d.config.statementTimeout())
+		defer cancel()
+
+		release := fmt.Sprintf(`UPDATE %s SET locked = false, locked_at = NULL WHERE id = 1`, d.config.lockTable())
+		if _, err := d.db.ExecContext(ctx, release); err != nil {
+			d.logf("Releasing the migration lock: %v", err)
+		}
+	}, nil
+}
+
+// Unlock releases the lock table lock left behind by a run killed while
+// holding it. Advisory and row locks end with the session holding them, so
+// they are never left behind.
+func (d *Dbmig) Unlock(args []string) error {
+	if len(args) == 0 || args[0] != "unlock" {
+		return fmt.Errorf("Invalid call %v", args)
+	}
+
+	var yes bool
+	fs := flag.NewFlagSet("unlock", flag.ContinueOnError)
+	fs.BoolVar(&yes, "yes", false, "Release the lock without asking")
+
+	if _, err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+
+	if strategy := d.config.lockStrategy(); strategy != lockTable {
+		return fmt.Errorf("Nothing to unlock, the %s lock is released when the run holding it ends", strategy)
+	}
+	if err := createLockTable(d); err != nil {
+		return err
+	}
+
+	ctx, cancel := d.statementContext()
+	defer cancel()
+
+	var locked bool
+	var lockedAt sql.NullString
+	query := fmt.Sprintf(`SELECT locked, locked_at FROM %s WHERE id = 1`, d.config.lockTable())
+	if err := d.db.QueryRowContext(ctx, query).Scan(&locked, &lockedAt); err != nil {
+		return err
+	}
+	if !locked {
+		d.printf("The migration lock in %s isn't held\n", d.config.lockTable())
+		return nil
+	}
+
+	if !yes {
+		ok, err := confirm(d, fmt.Sprintf("The migration lock in %s was taken at %s. Release it? Only do so when no run is migrating", d.config.lockTable(), lockedAt.String))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("Unlock aborted")
+		}
+	}
+
+	release := fmt.Sprintf(`UPDATE %s SET locked = false, locked_at = NULL WHERE id = 1`, d.config.lockTable())
+	if _, err := d.db.ExecContext(ctx, release); err != nil {
+		return err
+	}
+	d.printf("Released the migration lock in %s\n", d.config.lockTable())
+
+	return nil
+}
+
+// acquireRowLock locks the row of the lock table with SELECT ... FOR UPDATE
+// in a transaction kept open for the run, so other runs block on the row
+// until it ends. Unlike the lock table strategy, a crashed run can't leave
+// the lock behind: its transaction ends with its connection.
+func acquireRowLock(d *Dbmig) (func(), error) {
+	if err := createLockTable(d); err != nil {
+		return nil, err
+	}
+
+	claim := fmt.Sprintf(`SELECT id FROM %s WHERE id = 1 FOR UPDATE`, d.config.lockTable())
+	tx, err := lockRowTx(d, claim+" NOWAIT")
+	if sqlState(err) == "55P03" {
+		d.logf("Waiting for the migration lock on %s, held by another run", d.config.lockTable())
+		tx, err = lockRowTx(d, claim)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Taking the migration lock: %w", err)
+	}
+
+	return func() {
+		if err := tx.Rollback(); err != nil {
+			d.logf("Releasing the migration lock: %v", err)
+		}
+	}, nil
+}
+
+// lockRowTx begins a transaction and runs claim in it, returning the
+// transaction when claim succeeded.
+func lockRowTx(d *Dbmig, claim string) (*sql.Tx, error) {
+	tx, err := d.db.BeginTx(d.context(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var id int
+	if err := tx.QueryRowContext(d.context(), claim).Scan(&id); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// createLockTable creates the lock table and its single row, unless they
+// already exist.
+func createLockTable(d *Dbmig) error {
+	ctx, cancel := d.statementContext()
+	defer cancel()
+
+	create := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		id INTEGER PRIMARY KEY,
+		locked BOOLEAN NOT NULL DEFAULT false,
+		locked_at TIMESTAMP
+	)`, d.config.lockTable())
+	if _, err := d.db.ExecContext(ctx, create); err != nil {
+		return fmt.Errorf("Creating the lock table: %w", err)
+	}
+
+	seed := fmt.Sprintf(`INSERT INTO %[1]s (id, locked) SELECT 1, false WHERE NOT EXISTS (SELECT 1 FROM %[1]s WHERE id = 1)`, d.config.lockTable())
+	if _, err := d.db.ExecContext(ctx, seed); err != nil {
+		return fmt.Errorf("Creating the lock table: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+//go:build sqlite
+// +build sqlite
+
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReadOnlyCommandsWhileLocked(t *testing.T) {
+	d := newTestDbmig(t, map[string]string{
+		"1600000000_a.sql": "CREATE TABLE a (id INTEGER);\n/*DOWN*/\nDROP TABLE a;\n",
+		"1600000100_b.sql": "CREATE TABLE b (id INTEGER);\n/*DOWN*/\nDROP TABLE b;\n",
+	})
+	mustRun(t, d, "migrate up 1")
+
+	// Another run holds the migration lock.
+	release, err := acquireLock(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	polling := d.WithContext(ctx)
+	for _, line := range []string{"status", "current", "history", "plan", "verify"} {
+		mustRun(t, polling, line)
+	}
+
+	// migrate does wait for the lock.
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := runCommand(d.WithContext(ctx), []string{"migrate", "up", "all"}); err == nil {
+		t.Error("migrate up ran while the lock was held")
+	}
+}
+
+func TestUnlockAfterKilledRun(t *testing.T) {
+	d := newTestDbmig(t, nil)
+
+	// A killed run never releases the lock it took.
+	if _, err := acquireLock(d); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := acquireLock(d.WithContext(ctx)); err == nil {
+		t.Fatal("took the lock left behind by the killed run")
+	}
+
+	mustRun(t, d, "unlock --yes")
+	mustRun(t, d, "migrate up all")
+}
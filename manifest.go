@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// defaultManifest is the file manifest writes and checks, in the format of
+// sha256sum with paths relative to the migrations folder.
+const defaultManifest string = "dbmi.manifest"
+
+// manifestFiles returns the files a manifest covers: the migrations, with
+// their down and assertion files when they have some.
+func manifestFiles(c *Config) []string {
+	files := make([]string, 0)
+	for _, fname := range migrationFilenames(c) {
+		if isGoMigration(fname) {
+			continue
+		}
+		files = append(files, fname)
+
+		companions := []string{testFileFor(fname)}
+		if isSplitMigration(fname) {
+			companions = append(companions, downFileFor(fname))
+		}
+		for _, companion := range companions {
+			if _, err := os.Stat(path.Join(c.Folder, companion)); err == nil {
+				files = append(files, companion)
+			}
+		}
+	}
+	sort.Strings(files)
+
+	return files
+}
+
+// fileChecksums returns the SHA-256 of the files, as they are on disk.
+func fileChecksums(c *Config, files []string) (map[string]string, error) {
+	sums := map[string]string{}
+	for _, name := range files {
+		data, err := ioutil.ReadFile(path.Join(c.Folder, name))
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		sums[name] = hex.EncodeToString(sum[:])
+	}
+
+	return sums, nil
+}
+
+func readManifest(fpath string) (map[string]string, error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sums := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, "  ", 2)
+		if len(fields) != 2 || len(fields[0]) != sha256.Size*2 {
+			return nil, fmt.Errorf("%s:%d: expected <sha256>  <file>", fpath, n)
+		}
+		sums[fields[1]] = fields[0]
+	}
+
+	return sums, scanner.Err()
+}
+
+// Manifest writes the checksums of the migration files to a file to commit,
+// or with --check compares the files with it, so CI catches migrations
+// edited after review, applied or not.
+func (d *Dbmig) Manifest(args []string) error {
+	if len(args) == 0 || args[0] != "manifest" {
+		return fmt.Errorf("Invalid call %v", args)
+	}
+
+	var check bool
+	var file string
+	fs := flag.NewFlagSet("manifest", flag.ContinueOnError)
+	fs.BoolVar(&check, "check", false, "Compare the migration files with the manifest, failing on any difference")
+	fs.StringVar(&file, "file", defaultManifest, "The manifest file")
+
+	if _, err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+
+	files := manifestFiles(d.config)
+	sums, err := fileChecksums(d.config, files)
+	if err != nil {
+		return err
+	}
+
+	if !check {
+		var b strings.Builder
+		for _, name := range files {
+			fmt.Fprintf(&b, "%s  %s\n", sums[name], name)
+		}
+		if err := ioutil.WriteFile(file, []byte(b.String()), 0644); err != nil {
+			return err
+		}
+		d.printf("Wrote the checksums of %d files to %s\n", len(files), file)
+		return nil
+	}
+
+	recorded, err := readManifest(file)
+	if err != nil {
+		return err
+	}
+
+	problems := 0
+	for _, name := range files {
+		sum, ok := recorded[name]
+		switch {
+		case !ok:
+			d.printf("new\t%s\n", name)
+			problems++
+		case sum != sums[name]:
+			d.printf("changed\t%s\n", name)
+			problems++
+		}
+	}
+	removed := make([]string, 0)
+	for name := range recorded {
+		if _, ok := sums[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+	for _, name := range removed {
+		d.printf("removed\t%s\n", name)
+		problems++
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("%d files differ from %s; once reviewed, run `%s manifest` to update it", problems, file, programName)
+	}
+	d.printf("All %d files match %s\n", len(files), file)
+
+	return nil
+}
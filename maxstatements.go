@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// maxStatements returns the most statements a migration section may have,
+// from --max-statements or max_statements_per_migration, or 0 for no limit.
+func (d *Dbmig) maxStatements() int {
+	if d.opts.maxStatements > 0 {
+		return d.opts.maxStatements
+	}
+
+	return d.config.MaxStatements
+}
+
+// checkStatementCount fails when the direction section of the migration
+// file read from r has more statements than the limit, before any of them
+// runs. It stops reading at the first statement past the limit, so a dump
+// committed as a migration by accident isn't read through.
+func checkStatementCount(d *Dbmig, fname string, r io.Reader, direction string) error {
+	limit := d.maxStatements()
+	if limit <= 0 {
+		return nil
+	}
+
+	scanner := newStatementScanner(r)
+	section := "up"
+	count := 0
+	for count <= limit {
+		stmt, separator, err := scanner.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Reading %s: %v", fname, err)
+		}
+
+		if section == direction && stmt != "" {
+			count++
+		}
+		if separator {
+			if direction == "up" {
+				return nil
+			}
+			section = "down"
+		}
+	}
+
+	return fmt.Errorf("Migration %s has more than %d statements in its %s section, the limit set with max_statements_per_migration or --max-statements", fname, limit, direction)
+}
+
+// checkMigrationSize fails when the migration fname, of size bytes, is
+// larger than max_migration_bytes. There is no limit when it is 0.
+func checkMigrationSize(c *Config, fname string, size int64) error {
+	if c.MaxMigrationSize <= 0 || size <= c.MaxMigrationSize {
+		return nil
+	}
+
+	return fmt.Errorf("Migration %s is %d bytes, more than the %d bytes of max_migration_bytes", fname, size, c.MaxMigrationSize)
+}
+
+// checkBatchSizes checks the size of each migration of batch before any is
+// applied, so an oversized one doesn't fail a run halfway through.
+func checkBatchSizes(d *Dbmig, batch []string) error {
+	if d.config.MaxMigrationSize <= 0 {
+		return nil
+	}
+
+	for _, fname := range batch {
+		if isGoMigration(fname) {
+			continue
+		}
+
+		f, err := openMigrationFile(d.config, fname)
+		if err != nil {
+			return err
+		}
+		f.Close()
+
+		if err := checkMigrationSize(d.config, fname, f.size); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// writeMetrics writes the outcome of a migrate run to path in the
+// Prometheus text format, for node_exporter's textfile collector. The file is
+// replaced atomically so the collector never reads it half written.
+func writeMetrics(d *Dbmig, path string, duration time.Duration, success bool) error {
+	pending, applied, err := pendingMigrations(d)
+	if err != nil {
+		return err
+	}
+
+	table := d.config.trackingTableName()
+	ok := 0
+	if success {
+		ok = 1
+	}
+
+	var buf bytes.Buffer
+	gauge := func(name string, help string, value interface{}) {
+		fmt.Fprintf(&buf, "# HELP %s %s\n", name, help)
+		fmt.Fprintf(&buf, "# TYPE %s gauge\n", name)
+		fmt.Fprintf(&buf, "%s{table=%q} %v\n", name, table, value)
+	}
+	gauge("dbmi_migrations_applied_total", "Number of applied migrations.", len(applied))
+	gauge("dbmi_pending_migrations", "Number of migrations waiting to be applied.", len(pending))
+	gauge("dbmi_last_run_duration_seconds", "Duration of the last migrate run.", duration.Seconds())
+	gauge("dbmi_last_run_success", "Whether the last migrate run succeeded.", ok)
+	gauge("dbmi_last_run_timestamp_seconds", "When the last migrate run finished.", time.Now().Unix())
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".dbmi-metrics-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
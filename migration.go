@@ -0,0 +1,107 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// directivePrefix marks in-file headers such as `-- dbmi:isolation: serializable`.
+const directivePrefix string = "-- dbmi:"
+
+type migration struct {
+	Name       string
+	Up         string
+	Down       string
+	directives map[string][]string
+}
+
+// parseMigration splits migration file contents into its up and down
+// sections and collects any dbmi directives. ok is false when the file
+// doesn't contain exactly one separator, unless it is marked forward-only
+// and contains none.
+func parseMigration(name string, data string) (m *migration, ok bool) {
+	directives := parseDirectives(data)
+	spl := strings.Split(data, migrationSeparator)
+
+	if _, forwardOnly := directives["forward-only"]; forwardOnly && len(spl) == 1 {
+		return &migration{Name: name, Up: data, directives: directives}, true
+	}
+	if len(spl) != 2 {
+		return nil, false
+	}
+
+	m = &migration{Name: name, Up: spl[0], Down: spl[1], directives: directives}
+	return m, true
+}
+
+// forwardOnly reports whether the migration has no down section.
+func (m *migration) forwardOnly() bool {
+	_, ok := m.directive("forward-only")
+	return ok
+}
+
+// irreversible reports whether migrating down can't restore what the up
+// section removed, like dropped data. Its down section may be empty.
+func (m *migration) irreversible() bool {
+	_, ok := m.directive("irreversible")
+	return ok
+}
+
+func parseDirectives(data string) map[string][]string {
+	directives := map[string][]string{}
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, directivePrefix) {
+			continue
+		}
+
+		kv := strings.SplitN(strings.TrimPrefix(line, directivePrefix), ":", 2)
+		key := strings.ToLower(strings.TrimSpace(kv[0]))
+		value := ""
+		if len(kv) == 2 {
+			value = strings.TrimSpace(kv[1])
+		}
+		directives[key] = append(directives[key], value)
+	}
+
+	return directives
+}
+
+// directive returns the last value given for key and whether it was set.
+func (m *migration) directive(key string) (string, bool) {
+	values, ok := m.directives[key]
+	if !ok {
+		return "", false
+	}
+
+	return values[len(values)-1], true
+}
+
+var isolationLevels = map[string]sql.IsolationLevel{
+	"default":          sql.LevelDefault,
+	"read uncommitted": sql.LevelReadUncommitted,
+	"read committed":   sql.LevelReadCommitted,
+	"write committed":  sql.LevelWriteCommitted,
+	"repeatable read":  sql.LevelRepeatableRead,
+	"snapshot":         sql.LevelSnapshot,
+	"serializable":     sql.LevelSerializable,
+	"linearizable":     sql.LevelLinearizable,
+}
+
+// parseIsolationLevel maps names like "serializable" or "repeatable_read" to
+// the corresponding sql.IsolationLevel. An empty name is the driver default.
+func parseIsolationLevel(name string) (sql.IsolationLevel, error) {
+	if name == "" {
+		return sql.LevelDefault, nil
+	}
+
+	key := strings.ToLower(strings.TrimSpace(name))
+	key = strings.NewReplacer("_", " ", "-", " ").Replace(key)
+	level, ok := isolationLevels[key]
+	if !ok {
+		return sql.LevelDefault, fmt.Errorf("Invalid isolation level %q", name)
+	}
+
+	return level, nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestReadMigrationCRLF(t *testing.T) {
+	f, err := os.Open("testdata/crlf/1600000000_crlf.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	data, err := readMigration(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(data, "\r") {
+		t.Errorf("CR left in %q", data)
+	}
+
+	m, ok := parseMigration("1600000000_crlf.sql", data)
+	if !ok {
+		t.Fatal("the separator doesn't split the CRLF migration")
+	}
+	if got := strings.TrimSpace(m.Down); got != "DROP TABLE items;" {
+		t.Errorf("down section %q", got)
+	}
+
+	lf := "CREATE TABLE items (\n  id INTEGER\n);\n/*DOWN*/\nDROP TABLE items;\n"
+	if migrationChecksum(data) != migrationChecksum(lf) {
+		t.Error("the checksum of the CRLF migration differs from that of its LF copy")
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// withTrackingTable returns a copy of d using the tracking table name, given
+// as "table" or "schema.table".
+func (d *Dbmig) withTrackingTable(name string) (*Dbmig, error) {
+	config := *d.config
+	config.Schema, config.Tablename = "", name
+	if i := strings.Index(name, "."); i >= 0 {
+		config.Schema, config.Tablename = name[:i], name[i+1:]
+	}
+	if config.Tablename == "" || (config.Schema != "" && !d.dialect().schemas) {
+		return nil, fmt.Errorf("Invalid tracking table %q", name)
+	}
+
+	copied := *d
+	copied.config = &config
+	return &copied, nil
+}
+
+// trackingColumnNames returns the columns of the tracking table of d.
+func trackingColumnNames(d *Dbmig) ([]string, error) {
+	ctx, cancel := d.statementContext()
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %s WHERE 1 = 0", d.config.trackingTable()))
+	if err != nil {
+		return nil, trackingTableError(d, err)
+	}
+	defer rows.Close()
+
+	return rows.Columns()
+}
+
+// MoveTracking copies the history of one tracking table into another, for
+// when db_dbmi_tablename or db_schema change. The target is created when
+// missing and must be empty; the source is left for the user to drop.
+func (d *Dbmig) MoveTracking(args []string) error {
+	if len(args) == 0 || args[0] != "move-tracking" {
+		return fmt.Errorf("Invalid call %v", args)
+	}
+
+	var from, to string
+	fs := flag.NewFlagSet("move-tracking", flag.ContinueOnError)
+	fs.StringVar(&from, "from", "", "Tracking table to copy from, as <table> or <schema>.<table>")
+	fs.StringVar(&to, "to", "", "Tracking table to copy to (default the configured one)")
+
+	if _, err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+	if from == "" {
+		return fmt.Errorf("Usage: %s move-tracking --from <table> [--to <table>]", programName)
+	}
+
+	src, err := d.withTrackingTable(from)
+	if err != nil {
+		return err
+	}
+	dst := d
+	if to != "" {
+		if dst, err = d.withTrackingTable(to); err != nil {
+			return err
+		}
+	}
+	if src.config.trackingTable() == dst.config.trackingTable() {
+		return fmt.Errorf("Tracking table %s can't be moved onto itself", src.config.trackingTableName())
+	}
+
+	exists, err := trackingTableExists(src)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("Tracking table '%s' does not exist", src.config.trackingTableName())
+	}
+
+	// Creates the target with all its columns, or upgrades it.
+	if err := dst.InitMigrations(dst.context()); err != nil {
+		return err
+	}
+
+	srcColumns, err := trackingColumnNames(src)
+	if err != nil {
+		return err
+	}
+	dstColumns, err := trackingColumnNames(dst)
+	if err != nil {
+		return err
+	}
+
+	// Columns only the source has are left out, like ones added by hand.
+	dstSet := toSet(dstColumns)
+	columns := make([]string, 0, len(srcColumns))
+	for _, column := range srcColumns {
+		if dstSet[column] && columnNameRe.MatchString(column) {
+			columns = append(columns, d.dialect().quoteIdent(column))
+		}
+	}
+
+	ctx, cancel := d.statementContext()
+	defer cancel()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var existing int
+	if err := tx.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s", dst.config.trackingTable())).Scan(&existing); err != nil {
+		return err
+	}
+	if existing > 0 {
+		return fmt.Errorf("Tracking table '%s' already has %d rows, refusing to mix histories", dst.config.trackingTableName(), existing)
+	}
+
+	list := strings.Join(columns, ", ")
+	result, err := tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s ORDER BY id", dst.config.trackingTable(), list, list, src.config.trackingTable()))
+	if err != nil {
+		return err
+	}
+
+	if d.dialect().resetSequence != "" {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(d.dialect().resetSequence, dst.config.trackingTable())); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	copied, _ := result.RowsAffected()
+	d.printf("Copied %d rows from '%s' to '%s'. Once the config points at '%s', drop '%s'\n", copied, src.config.trackingTableName(), dst.config.trackingTableName(), dst.config.trackingTableName(), src.config.trackingTableName())
+
+	return nil
+}
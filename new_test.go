@@ -0,0 +1,88 @@
+package main
+
+import (
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+)
+
+var longName = strings.Repeat("add a column to the users table ", 10)
+
+func TestMigrationSlug(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Add users", "add_users"},
+		{"__add  users__", "add_users"},
+		{"Crée la table", "cr_e_la_table"},
+		{"ÄÖÜ users ß", "users"},
+		{"add 🚀 rockets", "add_rockets"},
+		{"🚀🚀", ""},
+		{longName, "add_a_column_to_the_users_table_add_a_column_to_the_users_table"},
+	}
+
+	for _, tt := range tests {
+		got := migrationSlug(tt.name)
+		if got != tt.want {
+			t.Errorf("migrationSlug(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+		if len(got) > maxSlugLength || strings.HasSuffix(got, "_") {
+			t.Errorf("migrationSlug(%q) = %q, longer than %d or ending in _", tt.name, got, maxSlugLength)
+		}
+	}
+}
+
+func TestNewMigrationNames(t *testing.T) {
+	for _, name := range []string{"Crée la table", "add 🚀 rockets", longName} {
+		t.Run(name, func(t *testing.T) {
+			d := &Dbmig{config: defaultConfig()}
+			d.config.Folder = t.TempDir()
+			d.SetOutput(ioutil.Discard, ioutil.Discard)
+
+			mustNew(t, d, name)
+
+			names := migrationFilenames(d.config)
+			if len(names) != 1 {
+				t.Fatalf("migrations %v, want one", names)
+			}
+			fname := names[0]
+			if _, ok := migrationTime(fname); !ok {
+				t.Errorf("%s has no timestamp prefix", fname)
+			}
+			if len(fname) > 256 {
+				t.Errorf("%s is longer than the name column", fname)
+			}
+
+			data, err := readMigrationFile(d, fname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := parseMigration(fname, data); !ok {
+				t.Errorf("%s doesn't parse", fname)
+			}
+			if want := migrationSlug(name); !strings.HasSuffix(path.Base(fname), "_"+want+".sql") {
+				t.Errorf("%s isn't named after %q", fname, want)
+			}
+		})
+	}
+}
+
+func TestNewMigrationWithoutLetters(t *testing.T) {
+	d := &Dbmig{config: defaultConfig()}
+	d.config.Folder = t.TempDir()
+	d.SetOutput(ioutil.Discard, ioutil.Discard)
+
+	if err := d.NewMigration([]string{"new", "🚀🚀"}); err == nil {
+		t.Error("created a migration named without letters or digits")
+	}
+}
+
+func mustNew(t *testing.T, d *Dbmig, name string) {
+	t.Helper()
+
+	if err := d.NewMigration([]string{"new", name}); err != nil {
+		t.Fatalf("new %q: %v", name, err)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Values of db_traversal. flat sorts the migrations of all subfolders
+// together by timestamp, grouped sorts by subfolder first.
+const (
+	traversalFlat    string = "flat"
+	traversalGrouped string = "grouped"
+)
+
+func validateTraversal(c *Config) error {
+	if c.Traversal != "" && c.Traversal != traversalFlat && c.Traversal != traversalGrouped {
+		return fmt.Errorf("Unknown db_traversal %q, expected %s or %s", c.Traversal, traversalFlat, traversalGrouped)
+	}
+	if c.MaxDepth < 0 {
+		return fmt.Errorf("db_max_depth must not be negative")
+	}
+
+	return nil
+}
+
+// sortMigrations orders migration filenames by their timestamp prefix,
+// falling back to the filename for ties. Unprefixed names, like repeatable
+// migrations, come last in name order. With grouped
+// traversal, migrations are first ordered by subfolder, the top folder first.
+func sortMigrations(c *Config, names []string) {
+	sort.SliceStable(names, func(i, j int) bool {
+		if c.Traversal == traversalGrouped {
+			di, dj := path.Dir(names[i]), path.Dir(names[j])
+			if di != dj {
+				return di == "." || (dj != "." && di < dj)
+			}
+		}
+		ti, iok := migrationTime(names[i])
+		tj, jok := migrationTime(names[j])
+		if iok != jok {
+			return iok
+		}
+		if iok && !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return names[i] < names[j]
+	})
+}
+
+// orderMigrations sorts the pending migrations by timestamp and then makes
+// sure each one comes after the migrations it declares with
+// `-- dbmi:requires: <filename>`. Requirements that are already applied are
+// satisfied; unknown requirements and cycles are errors.
+func orderMigrations(d *Dbmig, pending []string, applied []string) ([]string, error) {
+	sorted := make([]string, len(pending))
+	copy(sorted, pending)
+	sortMigrations(d.config, sorted)
+
+	requires := map[string][]string{}
+	pendingSet := toSet(sorted)
+	appliedSet := toSet(applied)
+
+	for _, fname := range sorted {
+		if isGoMigration(fname) {
+			continue
+		}
+
+		data, err := readMigrationFile(d, fname)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, req := range parseDirectives(data)["requires"] {
+			if req == "" {
+				continue
+			}
+			if !pendingSet[req] && !appliedSet[req] {
+				return nil, fmt.Errorf("Migration %s requires %s, which is neither applied nor pending", fname, req)
+			}
+			requires[fname] = append(requires[fname], req)
+		}
+	}
+
+	ordered := make([]string, 0, len(sorted))
+	done := map[string]bool{}
+
+	for len(ordered) < len(sorted) {
+		progress := false
+		for _, fname := range sorted {
+			if done[fname] {
+				continue
+			}
+
+			ready := true
+			for _, req := range requires[fname] {
+				if !done[req] && !appliedSet[req] {
+					ready = false
+					break
+				}
+			}
+
+			if ready {
+				ordered = append(ordered, fname)
+				done[fname] = true
+				progress = true
+				break
+			}
+		}
+
+		if !progress {
+			blocked := make([]string, 0)
+			for _, fname := range sorted {
+				if !done[fname] {
+					blocked = append(blocked, fname)
+				}
+			}
+			return nil, fmt.Errorf("Cyclic requires between migrations: %s", strings.Join(blocked, ", "))
+		}
+	}
+
+	return ordered, nil
+}
+
+// onlyMatching keeps the pending migrations matching the glob pattern, in
+// their apply order. A match requiring a pending migration that doesn't
+// match is an error, as it can't be applied first.
+func onlyMatching(d *Dbmig, pending []string, pattern string) ([]string, error) {
+	matched := make([]string, 0)
+	for _, fname := range pending {
+		ok, err := path.Match(pattern, fname)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid --only pattern %q: %v", pattern, err)
+		}
+		if ok {
+			matched = append(matched, fname)
+		}
+	}
+
+	pendingSet := toSet(pending)
+	matchedSet := toSet(matched)
+	for _, fname := range matched {
+		if isGoMigration(fname) {
+			continue
+		}
+
+		data, err := readMigrationFile(d, fname)
+		if err != nil {
+			return nil, err
+		}
+		for _, req := range parseDirectives(data)["requires"] {
+			if pendingSet[req] && !matchedSet[req] {
+				return nil, fmt.Errorf("Migration %s requires %s, which is pending and doesn't match --only %q", fname, req, pattern)
+			}
+		}
+	}
+
+	return matched, nil
+}
+
+// excludeMatching drops the pending migrations matching the glob pattern,
+// returning the kept ones in their apply order and the excluded ones. Keeping
+// a migration requiring an excluded one is an error, as it can't be applied
+// without it.
+func excludeMatching(d *Dbmig, pending []string, pattern string) (kept []string, excluded []string, err error) {
+	kept, excluded = make([]string, 0), make([]string, 0)
+	for _, fname := range pending {
+		ok, err := path.Match(pattern, fname)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Invalid --exclude pattern %q: %v", pattern, err)
+		}
+		if ok {
+			excluded = append(excluded, fname)
+		} else {
+			kept = append(kept, fname)
+		}
+	}
+
+	excludedSet := toSet(excluded)
+	for _, fname := range kept {
+		if isGoMigration(fname) {
+			continue
+		}
+
+		data, err := readMigrationFile(d, fname)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, req := range parseDirectives(data)["requires"] {
+			if excludedSet[req] {
+				return nil, nil, fmt.Errorf("Migration %s requires %s, which is excluded by --exclude %q", fname, req, pattern)
+			}
+		}
+	}
+
+	return kept, excluded, nil
+}
@@ -0,0 +1,77 @@
+//go:build sqlite
+// +build sqlite
+
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// outOfOrderDbmig applies 1600000000_a.sql and 1600000200_c.sql, then
+// 1600000100_b.sql, added afterwards like after a merge.
+func outOfOrderDbmig(t *testing.T) *Dbmig {
+	t.Helper()
+
+	d := newTestDbmig(t, map[string]string{
+		"1600000000_a.sql": "CREATE TABLE a (id INTEGER);\n/*DOWN*/\nDROP TABLE a;\n",
+		"1600000200_c.sql": "CREATE TABLE c (id INTEGER);\n/*DOWN*/\nDROP TABLE c;\n",
+	})
+	mustRun(t, d, "migrate up all")
+	writeTestMigrations(t, d.config.Folder, map[string]string{
+		"1600000100_b.sql": "CREATE TABLE b (id INTEGER);\n/*DOWN*/\nDROP TABLE b;\n",
+	})
+	mustRun(t, d, "apply 1600000100_b.sql")
+
+	return d
+}
+
+func TestDownOutOfOrderApplied(t *testing.T) {
+	tests := []struct {
+		flags string
+		want  []string
+	}{
+		{"", []string{"[1600000000_a.sql 1600000200_c.sql]", "[1600000000_a.sql]", "[]"}},
+		{"--dir-order applied", []string{"[1600000000_a.sql 1600000200_c.sql]", "[1600000000_a.sql]", "[]"}},
+		{"--dir-order filename", []string{"[1600000000_a.sql 1600000100_b.sql]", "[1600000000_a.sql]", "[]"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.flags, func(t *testing.T) {
+			d := outOfOrderDbmig(t)
+			if got := appliedNames(t, d); fmt.Sprint(got) != "[1600000000_a.sql 1600000200_c.sql 1600000100_b.sql]" {
+				t.Fatalf("applied %v", got)
+			}
+
+			for _, want := range tt.want {
+				mustRun(t, d, "migrate down 1 "+tt.flags)
+				if got := fmt.Sprint(appliedNames(t, d)); got != want {
+					t.Fatalf("after migrate down 1 %s, applied %s, want %s", tt.flags, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDownSharedTimestamp(t *testing.T) {
+	for i := 0; i < 3; i++ {
+		d := newTestDbmig(t, map[string]string{
+			"1600000000_a.sql": "CREATE TABLE a (id INTEGER);\n/*DOWN*/\nDROP TABLE a;\n",
+			"1600000000_b.sql": "CREATE TABLE b (id INTEGER);\n/*DOWN*/\nDROP TABLE b;\n",
+		})
+		mustRun(t, d, "migrate up all")
+
+		// Applied within the same instant, in one batch.
+		if _, err := d.db.Exec(`UPDATE db_migrations SET version = 1, created_at = '2020-09-13 12:26:40'`); err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprint(appliedNames(t, d)); got != "[1600000000_a.sql 1600000000_b.sql]" {
+			t.Fatalf("applied %s", got)
+		}
+
+		mustRun(t, d, "migrate down 1")
+		if got := fmt.Sprint(appliedNames(t, d)); got != "[1600000000_a.sql]" {
+			t.Fatalf("run %d: migrate down 1 left %s, want [1600000000_a.sql]", i, got)
+		}
+	}
+}
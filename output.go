@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// SetOutput redirects what d prints to stdout, and its log lines, progress
+// and warnings, to stderr, for instance to capture them when dbmi is
+// embedded. By default they go to os.Stdout and the standard logger.
+func (d *Dbmig) SetOutput(stdout io.Writer, stderr io.Writer) {
+	d.out = stdout
+	d.logger = log.New(stderr, log.Prefix(), log.Flags())
+	if d.config != nil {
+		d.config.logger = d.logger
+	}
+}
+
+func (d *Dbmig) stdout() io.Writer {
+	if d.out == nil {
+		return os.Stdout
+	}
+
+	return d.out
+}
+
+func (d *Dbmig) stderr() io.Writer {
+	if d.logger == nil {
+		return os.Stderr
+	}
+
+	return d.logger.Writer()
+}
+
+func (d *Dbmig) printf(format string, a ...interface{}) {
+	fmt.Fprintf(d.stdout(), format, a...)
+}
+
+func (d *Dbmig) println(a ...interface{}) {
+	fmt.Fprintln(d.stdout(), a...)
+}
+
+func (d *Dbmig) logf(format string, a ...interface{}) {
+	if d.logger == nil {
+		log.Printf(format, a...)
+		return
+	}
+
+	d.logger.Printf(format, a...)
+}
+
+// logf logs what is found reading the config's migrations folder, to the
+// logger SetOutput gave the Dbmig running on c.
+func (c *Config) logf(format string, a ...interface{}) {
+	if c.logger == nil {
+		log.Printf(format, a...)
+		return
+	}
+
+	c.logger.Printf(format, a...)
+}
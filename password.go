@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+var passwordKeywordRe = regexp.MustCompile(`(^|\s)password\s*=`)
+
+// hasPassword tells whether a connection string, either a URL or
+// keyword/value pairs, already carries a password.
+func hasPassword(dsn string) bool {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		if _, ok := u.User.Password(); ok {
+			return true
+		}
+		return u.Query().Get("password") != ""
+	}
+
+	return passwordKeywordRe.MatchString(dsn)
+}
+
+// withPassword adds password to the connection string.
+func withPassword(dsn string, password string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		username := ""
+		if u.User != nil {
+			username = u.User.Username()
+		}
+		u.User = url.UserPassword(username, password)
+		return u.String()
+	}
+
+	quoted := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(password)
+	return strings.TrimSpace(dsn + " password='" + quoted + "'")
+}
+
+// promptPassword reads the database password from the terminal, without
+// echo, unless the connection string already has one.
+func promptPassword(c *Config) error {
+	if hasPassword(c.ConnectionString) {
+		return nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return fmt.Errorf("Cannot prompt for a password, stdin is not a terminal")
+	}
+
+	fmt.Fprint(os.Stderr, "Database password: ")
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return fmt.Errorf("Reading password: %w", err)
+	}
+
+	c.ConnectionString = withPassword(c.ConnectionString, string(password))
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const defaultPingQuery string = "SELECT 1"
+
+func (c *Config) pingQuery() string {
+	if c.PingQuery == "" {
+		return defaultPingQuery
+	}
+
+	return c.PingQuery
+}
+
+// pingDatabase checks that db is usable: Ping only proves a connection was
+// accepted, which a proxy does even when no database is behind it, so the
+// ping query is run too.
+func pingDatabase(ctx context.Context, c *Config, db *sql.DB) error {
+	if err := db.PingContext(ctx); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.statementTimeout())
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, c.pingQuery())
+	if err != nil {
+		return fmt.Errorf("Connected, but the ping query %q failed, the database may not be reachable behind a proxy: %v", c.pingQuery(), err)
+	}
+
+	return rows.Close()
+}
+
+// Ping checks the connection without touching migrations or the tracking
+// table. main already connected and ran the ping query, so all that's left is
+// to show where to and what the server is.
+func (d *Dbmig) Ping(args []string) error {
+	if len(args) == 0 || args[0] != "ping" {
+		return fmt.Errorf("Invalid call %v", args)
+	}
+
+	ctx, cancel := d.statementContext()
+	defer cancel()
+
+	var version string
+	if err := d.db.QueryRowContext(ctx, d.dialect().serverVersion).Scan(&version); err != nil {
+		return fmt.Errorf("Connected, but reading the server version failed: %v", redactPassword(err.Error(), d.config.ConnectionString))
+	}
+
+	d.printf("Connected to %s\n%s\n", redactPassword(d.config.ConnectionString, d.config.ConnectionString), version)
+
+	return nil
+}
+
+// reconnectAttempts is how many times checkConnection reconnects before it
+// gives up.
+const reconnectAttempts int = 3
+
+// checkConnection pings the database before the migration fname, with
+// migrate --check-connection-before-each, and reconnects when the ping
+// fails, waiting from db_retry_backoff on and twice as long each time.
+// database/sql drops connections that went bad, so each ping after one
+// failed dials again. Under
+// --all-in-one-tx every migration runs on the shared transaction, which a
+// new connection can't resume, so nothing is checked.
+func checkConnection(d *Dbmig, fname string) error {
+	if !d.opts.checkConnection || d.opts.sharedTx != nil {
+		return nil
+	}
+
+	err := d.pingOnce()
+	backoff := d.config.retryBackoff()
+	for attempt := 1; err != nil; attempt++ {
+		if attempt > reconnectAttempts {
+			return fmt.Errorf("Connection lost before %s, %d reconnects failed: %v", fname, reconnectAttempts, err)
+		}
+
+		d.logf("Connection lost before %s, reconnecting in %s (%d of %d): %v", fname, backoff, attempt, reconnectAttempts, redactPassword(err.Error(), d.config.ConnectionString))
+		select {
+		case <-time.After(backoff):
+		case <-d.context().Done():
+			return err
+		}
+		backoff *= 2
+
+		if err = d.pingOnce(); err == nil {
+			d.logf("Reconnected to %s", redactPassword(d.config.ConnectionString, d.config.ConnectionString))
+		}
+	}
+
+	return nil
+}
+
+func (d *Dbmig) pingOnce() error {
+	ctx, cancel := d.statementContext()
+	defer cancel()
+
+	return pingDatabase(ctx, d.config, d.db)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+// outOfOrder returns the pending migrations that are older than the newest
+// applied one, so applying them changes history rather than extending it.
+func outOfOrder(pending []string, applied []string) []string {
+	var newest time.Time
+	for _, fname := range applied {
+		if t, ok := migrationTime(fname); ok && t.After(newest) {
+			newest = t
+		}
+	}
+
+	result := make([]string, 0)
+	for _, fname := range pending {
+		if t, ok := migrationTime(fname); ok && t.Before(newest) {
+			result = append(result, fname)
+		}
+	}
+
+	return result
+}
+
+// Plan shows what `migrate up` would do without doing it, and fails when it
+// finds a problem that would stop the migration.
+func (d *Dbmig) Plan(args []string) error {
+	if len(args) == 0 || args[0] != "plan" {
+		return fmt.Errorf("Invalid call %v", args)
+	}
+
+	var porcelain bool
+	fs := flag.NewFlagSet("plan", flag.ContinueOnError)
+	fs.BoolVar(&porcelain, "porcelain", false, "Print a stable A <name> <applied at>, P <name> and SUMMARY format for scripts, and the problems on stderr")
+
+	if _, err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+
+	// Under --porcelain stdout only has the porcelain lines.
+	human := d.stdout()
+	if porcelain {
+		human = d.stderr()
+	}
+
+	blocking := 0
+
+	exists, err := trackingTableExists(d)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		fmt.Fprintf(human, "Tracking table '%s' does not exist; run `%s init`\n", d.config.trackingTableName(), programName)
+		return fmt.Errorf("plan found a blocking problem")
+	}
+
+	pending, applied, err := pendingMigrations(d)
+	if err != nil {
+		fmt.Fprintf(human, "Cannot order pending migrations: %v\n", err)
+		return fmt.Errorf("plan found a blocking problem")
+	}
+
+	repeatables, err := pendingRepeatables(d)
+	if err != nil {
+		return err
+	}
+	pending = append(pending, repeatables...)
+
+	drifted, err := driftedMigrations(d)
+	if err != nil {
+		return err
+	}
+	if len(drifted) > 0 {
+		fmt.Fprintf(human, "Applied migrations changed since they were applied:\n")
+		for _, fname := range drifted {
+			fmt.Fprintf(human, "  %s\n", fname)
+		}
+		return fmt.Errorf("plan found a blocking problem")
+	}
+
+	if porcelain {
+		times, err := appliedTimes(d)
+		if err != nil {
+			return err
+		}
+		printPorcelain(d, applied, pending, times)
+	}
+
+	if len(pending) == 0 {
+		fmt.Fprintf(human, "Nothing to do, all %d migrations are applied\n", len(applied))
+		return nil
+	}
+
+	if !porcelain {
+		d.printf("Pending migrations (%d), in apply order:\n", len(pending))
+		for i, fname := range pending {
+			d.printf("  %d. %s\n", i+1, fname)
+		}
+	}
+
+	warnings := make([]string, 0)
+	for _, fname := range pending {
+		for _, problem := range validateMigration(d, fname) {
+			warnings = append(warnings, fmt.Sprintf("error: %s: %s", fname, problem))
+			blocking++
+		}
+	}
+	for _, fname := range outOfOrder(pending, applied) {
+		warnings = append(warnings, fmt.Sprintf("warning: %s is older than the newest applied migration", fname))
+	}
+
+	if len(warnings) > 0 {
+		fmt.Fprintf(human, "\nProblems:\n")
+		for _, w := range warnings {
+			fmt.Fprintf(human, "  %s\n", w)
+		}
+	}
+
+	if blocking > 0 {
+		return fmt.Errorf("plan found %d blocking problems", blocking)
+	}
+
+	return nil
+}
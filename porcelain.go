@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// The porcelain output of status and plan is meant for scripts, and stays
+// the same across versions:
+//
+//	A <name> <applied at, RFC 3339 in UTC>
+//	P <name>
+//	SUMMARY applied=<n> pending=<m>
+//
+// Anything else goes to stderr.
+
+// appliedTimes returns when each applied migration was applied.
+func appliedTimes(d *Dbmig) (map[string]time.Time, error) {
+	ctx, cancel := d.statementContext()
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, fmt.Sprintf("SELECT name, created_at FROM %s%s", d.config.trackingTable(), d.config.whereApplied()))
+	if err != nil {
+		return nil, trackingTableError(d, err)
+	}
+	defer rows.Close()
+
+	times := map[string]time.Time{}
+	for rows.Next() {
+		var name string
+		var at time.Time
+		if err := rows.Scan(&name, &at); err != nil {
+			return nil, err
+		}
+		times[name] = at
+	}
+
+	return times, rows.Err()
+}
+
+// printPorcelain prints the applied migrations, then the pending ones.
+func printPorcelain(d *Dbmig, applied []string, pending []string, times map[string]time.Time) {
+	for _, fname := range applied {
+		d.printf("A %s %s\n", fname, times[fname].UTC().Format(time.RFC3339))
+	}
+	for _, fname := range pending {
+		d.printf("P %s\n", fname)
+	}
+	d.printf("SUMMARY applied=%d pending=%d\n", len(applied), len(pending))
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// runPreCheck runs db_pre_check_query, a safety gate written by the user,
+// like one looking for long-running transactions. Any row it returns stops
+// the migration, with the first column of each row as the reason.
+func runPreCheck(d *Dbmig) error {
+	if d.config.PreCheckQuery == "" {
+		return nil
+	}
+
+	ctx, cancel := d.statementContext()
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, d.config.PreCheckQuery)
+	if err != nil {
+		return fmt.Errorf("db_pre_check_query failed: %v", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	reasons := make([]string, 0)
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		for i := range values {
+			values[i] = new(sql.RawBytes)
+		}
+		if err := rows.Scan(values...); err != nil {
+			return err
+		}
+		reason := string(*values[0].(*sql.RawBytes))
+		if reason == "" {
+			reason = "(empty)"
+		}
+		reasons = append(reasons, reason)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(reasons) > 0 {
+		return fmt.Errorf("db_pre_check_query returned %d rows, not migrating (pass --skip-pre-check to override):\n  %s", len(reasons), strings.Join(reasons, "\n  "))
+	}
+
+	return nil
+}
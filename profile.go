@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime/trace"
+	"sort"
+	"sync"
+	"time"
+)
+
+// slowestShown is how many of the slowest migrations the profile lists.
+const slowestShown int = 5
+
+// profiler times the phases of a run under -profile, to tell waiting on the
+// lock apart from running SQL. A nil profiler times nothing.
+type profiler struct {
+	mu      sync.Mutex
+	started time.Time
+	phases  map[string]*phaseStats
+	// migrations is the time each migration took to execute.
+	migrations map[string]time.Duration
+}
+
+type phaseStats struct {
+	count int
+	total time.Duration
+	max   time.Duration
+}
+
+func newProfiler() *profiler {
+	return &profiler{started: time.Now(), phases: map[string]*phaseStats{}, migrations: map[string]time.Duration{}}
+}
+
+// time starts timing phase and returns the function stopping it. The phase is
+// also a region of the -profile-trace execution trace. name is the migration
+// the phase ran for, if any.
+func (p *profiler) time(phase string, name string) func() {
+	if p == nil {
+		return func() {}
+	}
+
+	region := trace.StartRegion(context.Background(), phase)
+	start := time.Now()
+
+	return func() {
+		elapsed := time.Since(start)
+		region.End()
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		stats, ok := p.phases[phase]
+		if !ok {
+			stats = &phaseStats{}
+			p.phases[phase] = stats
+		}
+		stats.count++
+		stats.total += elapsed
+		if elapsed > stats.max {
+			stats.max = elapsed
+		}
+		if name != "" {
+			p.migrations[name] += elapsed
+		}
+	}
+}
+
+// report prints the phases by the time spent in them, then the slowest
+// migrations.
+func (p *profiler) report(w io.Writer) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	wall := time.Since(p.started)
+	phases := make([]string, 0, len(p.phases))
+	for phase := range p.phases {
+		phases = append(phases, phase)
+	}
+	sort.Slice(phases, func(i, j int) bool { return p.phases[phases[i]].total > p.phases[phases[j]].total })
+
+	fmt.Fprintf(w, "Profile, %s in total:\n", wall.Round(time.Millisecond))
+	fmt.Fprintf(w, "  %-16s %6s %12s %6s %12s\n", "phase", "count", "total", "share", "max")
+	for _, phase := range phases {
+		stats := p.phases[phase]
+		share := 0.0
+		if wall > 0 {
+			share = 100 * float64(stats.total) / float64(wall)
+		}
+		fmt.Fprintf(w, "  %-16s %6d %12s %5.1f%% %12s\n", phase, stats.count, stats.total.Round(time.Microsecond), share, stats.max.Round(time.Microsecond))
+	}
+
+	names := make([]string, 0, len(p.migrations))
+	for name := range p.migrations {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return
+	}
+	sort.Slice(names, func(i, j int) bool { return p.migrations[names[i]] > p.migrations[names[j]] })
+	if len(names) > slowestShown {
+		names = names[:slowestShown]
+	}
+
+	fmt.Fprintf(w, "Slowest migrations:\n")
+	for _, name := range names {
+		fmt.Fprintf(w, "  %12s  %s\n", p.migrations[name].Round(time.Microsecond), name)
+	}
+}
+
+// startProfile sets up -profile and -profile-trace, and returns the function
+// printing the report and finishing the trace.
+func startProfile(c *Config, enabled bool, traceFile string) (func(), error) {
+	if !enabled && traceFile == "" {
+		return func() {}, nil
+	}
+
+	c.profile = newProfiler()
+
+	var f *os.File
+	if traceFile != "" {
+		var err error
+		if f, err = os.Create(traceFile); err != nil {
+			return nil, fmt.Errorf("Cannot create -profile-trace file: %v", err)
+		}
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("Cannot start -profile-trace: %v", err)
+		}
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.profile.report(os.Stderr)
+			if f != nil {
+				trace.Stop()
+				f.Close()
+			}
+		})
+	}, nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+var stdin = bufio.NewReader(os.Stdin)
+
+// ask prints question to d's stdout and returns the lowercased first word
+// of the answer.
+func ask(d *Dbmig, question string) (string, error) {
+	d.printf("%s", question)
+
+	line, err := stdin.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", fmt.Errorf("No answer to %q: %v", strings.TrimSpace(question), err)
+	}
+
+	return strings.ToLower(strings.TrimSpace(line)), nil
+}
+
+// confirm asks a yes/no question, treating anything but yes as no.
+func confirm(d *Dbmig, question string) (bool, error) {
+	answer, err := ask(d, question+" [y/N]: ")
+	if err != nil {
+		return false, err
+	}
+
+	return answer == "y" || answer == "yes", nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+)
+
+// mutatingCommands change the database and are guarded on protected hosts.
+var mutatingCommands = map[string]bool{
+	"init":             true,
+	"migrate":          true,
+	"apply":            true,
+	"exec":             true,
+	"move-tracking":    true,
+	"watch":            true,
+	"squash":           true,
+	"restore-tracking": true,
+	"unlock":           true,
+	"fix-checksums":    true,
+	"repair":           true,
+	"serve":            true,
+}
+
+var hostKeywordRe = regexp.MustCompile(`(?:^|\s)host\s*=\s*'?([^'\s]+)`)
+
+// connectionHost returns the host of a connection string, either a URL or
+// keyword/value pairs.
+func connectionHost(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		return u.Hostname()
+	}
+
+	if m := hostKeywordRe.FindStringSubmatch(dsn); m != nil {
+		return m[1]
+	}
+
+	return ""
+}
+
+// protectedHost returns the host of the connection string when it matches
+// one of the protected_hosts patterns, and "" otherwise.
+func (c *Config) protectedHost() string {
+	host := connectionHost(c.ConnectionString)
+	if host == "" {
+		return ""
+	}
+
+	for _, pattern := range c.ProtectedHosts {
+		if ok, _ := path.Match(pattern, host); ok {
+			return host
+		}
+	}
+
+	return ""
+}
+
+// guardProtectedHost refuses to run a mutating command against a protected
+// host unless it was confirmed with -confirm-production, or interactively
+// when stdin is a terminal.
+func guardProtectedHost(d *Dbmig, command string, confirmed bool) error {
+	host := d.config.protectedHost()
+	if host == "" || !mutatingCommands[command] || confirmed {
+		return nil
+	}
+
+	if !isTerminal(os.Stdin) {
+		return fmt.Errorf("%s is a protected host, pass -confirm-production to run %s against it", host, command)
+	}
+
+	ok, err := confirm(d, fmt.Sprintf("%s is a protected host. Run %s against it?", host, command))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("Not running %s against protected host %s", command, host)
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"database/sql"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+var commitHashRe = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// sourceCommit returns the git commit that last changed the file of
+// migration fname, as a value for the source_commit column: NULL when git
+// isn't installed, the folder isn't a repository, or the file isn't
+// committed.
+func sourceCommit(c *Config, fname string) sql.NullString {
+	if isGoMigration(fname) {
+		return sql.NullString{}
+	}
+
+	out, err := exec.Command("git", "-C", c.Folder, "log", "-1", "--format=%H", "--", fname).Output()
+	if err != nil {
+		c.logf("Not recording the source commit of %s: %v", fname, err)
+		return sql.NullString{}
+	}
+
+	hash := strings.TrimSpace(string(out))
+	if !commitHashRe.MatchString(hash) {
+		return sql.NullString{}
+	}
+
+	return sql.NullString{String: hash, Valid: true}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Values of db_log_sql, controlling how much of the migration SQL ends up
+// in logs and error messages.
+const (
+	logSQLFull     string = "full"
+	logSQLTruncate string = "truncate"
+	logSQLNone     string = "none"
+)
+
+// maxLoggedStatement is the length statements are cut to with db_log_sql
+// set to truncate.
+const maxLoggedStatement int = 200
+
+// redactedPassword replaces passwords in anything dbmi prints.
+const redactedPassword string = "xxxxx"
+
+func (c *Config) logSQL() string {
+	if c.LogSQL == "" {
+		return logSQLFull
+	}
+
+	return c.LogSQL
+}
+
+func validateLogSQL(c *Config) error {
+	switch c.logSQL() {
+	case logSQLFull, logSQLTruncate, logSQLNone:
+		return nil
+	}
+
+	return fmt.Errorf("Unknown db_log_sql %q, expected %s, %s or %s", c.LogSQL, logSQLFull, logSQLTruncate, logSQLNone)
+}
+
+// loggedStatement returns stmt as it may appear in logs, which is "" when
+// statements are not logged at all.
+func (c *Config) loggedStatement(stmt string) string {
+	switch c.logSQL() {
+	case logSQLNone:
+		return ""
+	case logSQLTruncate:
+		runes := []rune(stmt)
+		if len(runes) > maxLoggedStatement {
+			return fmt.Sprintf("%s... (%d more characters)", string(runes[:maxLoggedStatement]), len(runes)-maxLoggedStatement)
+		}
+	}
+
+	return stmt
+}
+
+var passwordValueRe = regexp.MustCompile(`(?:^|\s)password\s*=\s*('(?:[^'\\]|\\.)*'|\S+)`)
+
+// dsnPassword returns the password in a connection string, or "".
+func dsnPassword(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		if password, ok := u.User.Password(); ok {
+			return password
+		}
+		return u.Query().Get("password")
+	}
+
+	if m := passwordValueRe.FindStringSubmatch(dsn); m != nil {
+		return strings.Trim(m[1], "'")
+	}
+
+	return ""
+}
+
+// redactPassword masks the password of dsn wherever it appears in s, as is
+// and URL encoded, so connection strings and driver errors echoing them
+// can be printed.
+func redactPassword(s string, dsn string) string {
+	password := dsnPassword(dsn)
+	if password == "" {
+		return s
+	}
+
+	s = strings.Replace(s, password, redactedPassword, -1)
+	if encoded := url.QueryEscape(password); encoded != password {
+		s = strings.Replace(s, encoded, redactedPassword, -1)
+	}
+	if encoded := url.PathEscape(password); encoded != password {
+		s = strings.Replace(s, encoded, redactedPassword, -1)
+	}
+
+	return s
+}
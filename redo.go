@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+)
+
+// redoMigrations migrates down the last amount applied migrations, or all of
+// them when amount is zero, then migrates them up again in their original
+// order. It checks that the down sections undo cleanly and that the schema
+// rebuilds, stopping at the first failure. With backupDir, the tracking
+// table is snapshot there before anything is migrated down.
+func redoMigrations(d *Dbmig, amount int, dirOrder string, backupDir string, yes bool, forceIrreversible bool) error {
+	applied, err := downMigrations(d, amount, dirOrder)
+	if err != nil {
+		return err
+	}
+	applied = limitAmount(applied, amount)
+
+	if len(applied) == 0 {
+		d.printf("Nothing to do, no migrations are applied\n")
+		return nil
+	}
+	if limit := d.config.maxDownWithoutConfirm(); len(applied) > limit && !yes {
+		return fmt.Errorf("Refusing to redo %d migrations, more than max_down_without_confirm (%d), without --yes", len(applied), limit)
+	}
+
+	// Fail before reverting anything rather than halfway through.
+	for _, fname := range applied {
+		if isGoMigration(fname) || isStdinMigration(fname) {
+			continue
+		}
+		data, err := readMigrationFile(d, fname)
+		if err != nil {
+			return fmt.Errorf("Cannot redo %s: %v", fname, err)
+		}
+		if m, ok := parseMigration(fname, data); ok && m.forwardOnly() {
+			return fmt.Errorf("Cannot redo %s, it is forward-only", fname)
+		}
+	}
+
+	if err := guardMinVersion(d, applied); err != nil {
+		return err
+	}
+	if err := guardIrreversible(d, applied, forceIrreversible); err != nil {
+		return err
+	}
+	if err := guardStdinMigrations(d, applied); err != nil {
+		return err
+	}
+
+	if backupDir != "" {
+		if _, err := backupTrackingTable(d, backupDir); err != nil {
+			return fmt.Errorf("Backup of the tracking table failed, not redoing: %v", err)
+		}
+	}
+
+	d.logf("Redoing %d migrations: %v", len(applied), applied)
+	warnNonTransactionalDDL(d)
+
+	for _, fname := range applied {
+		if err := runMigration(d, fname, "down"); err != nil {
+			d.logf("Redo stopped migrating %s down", fname)
+			return err
+		}
+	}
+
+	for _, fname := range reversed(applied) {
+		if err := runMigration(d, fname, "up"); err != nil {
+			d.logf("Redo stopped migrating %s up", fname)
+			return err
+		}
+	}
+
+	return nil
+}
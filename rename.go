@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// Rename renames a pending migration on disk, keeping its timestamp prefix
+// and taking <new> as the name after it, or as the whole filename with
+// --keep-name=false. Applied migrations are refused: their filename is their
+// tracking key, and renaming one would orphan its tracking row.
+func (d *Dbmig) Rename(args []string) error {
+	if len(args) == 0 || args[0] != "rename" {
+		return fmt.Errorf("Invalid call %v", args)
+	}
+
+	var keepName bool
+	fs := flag.NewFlagSet("rename", flag.ContinueOnError)
+	fs.BoolVar(&keepName, "keep-name", true, "Keep the timestamp prefix of <old>, <new> being the name after it; false takes <new> as the whole filename")
+
+	positional, err := parseFlags(fs, args[1:])
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return fmt.Errorf("Usage: dbmi rename <old> <new> [--keep-name=false]")
+	}
+	from := positional[0]
+
+	if d.config.remoteFolder() {
+		return fmt.Errorf("Cannot rename migrations fetched from %s", d.config.source)
+	}
+	if isGoMigration(from) {
+		return fmt.Errorf("Migration %s is a Go migration, rename it where it is registered", from)
+	}
+
+	pending, applied, err := pendingMigrations(d)
+	if err != nil {
+		return err
+	}
+	if toSet(applied)[from] {
+		return fmt.Errorf("Cannot rename %s, it is applied and its filename is its tracking key. Migrate it down first", from)
+	}
+	if !toSet(pending)[from] {
+		return fmt.Errorf("No migration named %s in %s", from, d.config.Folder)
+	}
+
+	to, err := renamedMigration(from, positional[1], keepName)
+	if err != nil {
+		return err
+	}
+	if to == from {
+		return fmt.Errorf("Migration %s already has that name", from)
+	}
+	if _, err := os.Stat(fmt.Sprintf("%s/%s", d.config.Folder, to)); !os.IsNotExist(err) {
+		return fmt.Errorf("Cannot rename %s to %s, which already exists", from, to)
+	}
+	if !d.config.includePattern().MatchString(path.Base(to)) {
+		return fmt.Errorf("Cannot rename %s to %s, which doesn't match %s and wouldn't be a migration", from, to, d.config.includePattern())
+	}
+
+	if err := renameMigration(d, from, to, "", ""); err != nil {
+		return err
+	}
+
+	mapping := map[string]string{from: to}
+	for _, fname := range migrationFilenames(d.config) {
+		if isGoMigration(fname) {
+			continue
+		}
+		if err := renameRequires(d, fname, mapping); err != nil {
+			return err
+		}
+	}
+
+	d.printf("%s -> %s\n", from, to)
+
+	return nil
+}
+
+// renamedMigration returns the filename of migration from renamed to name,
+// in the same subfolder and format. With keepName, name replaces what comes
+// after the timestamp prefix.
+func renamedMigration(from string, name string, keepName bool) (string, error) {
+	suffix := ".sql"
+	if isSplitMigration(from) {
+		suffix = upSuffix
+	}
+
+	if !keepName {
+		name = strings.TrimSuffix(strings.TrimSuffix(name, upSuffix), ".sql")
+		if name == "" || strings.Contains(name, "/") {
+			return "", fmt.Errorf("Invalid migration filename %q", name)
+		}
+		return path.Join(path.Dir(from), name+suffix), nil
+	}
+
+	if _, ok := migrationTime(from); !ok {
+		return "", fmt.Errorf("Migration %s has no timestamp prefix to keep, pass --keep-name=false and the whole filename", from)
+	}
+	slug := migrationSlug(name)
+	if slug == "" {
+		return "", fmt.Errorf("Migration name %q has no letters or digits to name the file after", name)
+	}
+
+	base := path.Base(from)
+	prefix := base[:strings.Index(base, "_")+1]
+
+	return path.Join(path.Dir(from), prefix+slug+suffix), nil
+}
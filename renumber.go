@@ -0,0 +1,174 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// Renumber rewrites the timestamp prefixes of the pending migrations into an
+// increasing sequence following their apply order, after the newest applied
+// migration. Applied migrations are never renamed: their filename is their
+// tracking key.
+func (d *Dbmig) Renumber(args []string) error {
+	if len(args) == 0 || args[0] != "renumber" {
+		return fmt.Errorf("Invalid call %v", args)
+	}
+
+	var start, spacing int64
+	var dryRun bool
+	fs := flag.NewFlagSet("renumber", flag.ContinueOnError)
+	fs.Int64Var(&start, "start", 0, "Timestamp of the first pending migration (default its current one, or right after the newest applied migration)")
+	fs.Int64Var(&spacing, "spacing", 1, "Seconds between consecutive timestamps")
+	fs.BoolVar(&dryRun, "dry-run", false, "Print the new names without renaming anything")
+
+	if _, err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+
+	if spacing < 1 {
+		return fmt.Errorf("Invalid --spacing %d, expected at least 1", spacing)
+	}
+
+	if d.config.remoteFolder() {
+		return fmt.Errorf("Cannot renumber migrations fetched from %s", d.config.source)
+	}
+
+	pending, applied, err := pendingMigrations(d)
+	if err != nil {
+		return err
+	}
+
+	var newest int64
+	for _, fname := range applied {
+		if t, ok := migrationTime(fname); ok && t.Unix() > newest {
+			newest = t.Unix()
+		}
+	}
+
+	renamed := make([]string, 0, len(pending))
+	for _, fname := range pending {
+		if _, ok := migrationTime(fname); ok && !isGoMigration(fname) {
+			renamed = append(renamed, fname)
+		}
+	}
+	if len(renamed) == 0 {
+		d.printf("Nothing to do, no pending migration has a timestamp prefix\n")
+		return nil
+	}
+
+	if start != 0 && start <= newest {
+		return fmt.Errorf("Invalid --start %d, the newest applied migration has timestamp %d", start, newest)
+	}
+	if start == 0 {
+		t, _ := migrationTime(renamed[0])
+		start = t.Unix()
+		if start <= newest {
+			start = newest + spacing
+		}
+	}
+
+	mapping := map[string]string{}
+	for i, fname := range renamed {
+		base := path.Base(fname)
+		title := base[strings.Index(base, "_")+1:]
+		mapping[fname] = path.Join(path.Dir(fname), fmt.Sprintf("%d_%s", start+int64(i)*spacing, title))
+	}
+
+	renamedSet := toSet(renamed)
+	for _, fname := range migrationFilenames(d.config) {
+		if renamedSet[fname] {
+			continue
+		}
+		for old, name := range mapping {
+			if name == fname {
+				return fmt.Errorf("Cannot rename %s to %s, which already exists", old, name)
+			}
+		}
+	}
+
+	for _, fname := range renamed {
+		if mapping[fname] != fname {
+			d.printf("%s -> %s\n", fname, mapping[fname])
+		}
+	}
+	if dryRun {
+		return nil
+	}
+
+	// Rename through temporary names, as a new name may be the old name of
+	// another pending migration.
+	const tmpSuffix = ".renumber"
+	for _, fname := range renamed {
+		if err := renameMigration(d, fname, fname, "", tmpSuffix); err != nil {
+			return err
+		}
+	}
+	for _, fname := range renamed {
+		if err := renameMigration(d, fname, mapping[fname], tmpSuffix, ""); err != nil {
+			return err
+		}
+		if err := renameRequires(d, mapping[fname], mapping); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// renameMigration renames the files of migration from, with fromSuffix
+// appended, to those of migration to, with toSuffix appended. Its
+// assertion file and down file are renamed along when it has them.
+func renameMigration(d *Dbmig, from string, to string, fromSuffix string, toSuffix string) error {
+	pathOf := func(fname string) string {
+		return fmt.Sprintf("%s/%s", d.config.Folder, fname)
+	}
+
+	if err := os.Rename(pathOf(from+fromSuffix), pathOf(to+toSuffix)); err != nil {
+		return err
+	}
+
+	companions := [][2]string{{testFileFor(from), testFileFor(to)}}
+	if isSplitMigration(from) {
+		companions = append(companions, [2]string{downFileFor(from), downFileFor(to)})
+	}
+	for _, c := range companions {
+		if err := os.Rename(pathOf(c[0]+fromSuffix), pathOf(c[1]+toSuffix)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// renameRequires updates the requires directives of migration fname that
+// name a renamed migration.
+func renameRequires(d *Dbmig, fname string, mapping map[string]string) error {
+	fpath := fmt.Sprintf("%s/%s", d.config.Folder, fname)
+	data, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(string(data), "\n")
+	changed := false
+	for i, line := range lines {
+		directives := parseDirectives(line)
+		reqs, ok := directives["requires"]
+		if !ok {
+			continue
+		}
+		if name, ok := mapping[reqs[0]]; ok {
+			lines[i] = strings.Replace(line, reqs[0], name, 1)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	return ioutil.WriteFile(fpath, []byte(strings.Join(lines, "\n")), 0644)
+}
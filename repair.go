@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+// Repair reports the gaps in the applied history: pending migrations older
+// than the newest applied one, typically merged late. With --apply-missing
+// it applies just those, oldest first, leaving newer pending ones alone.
+func (d *Dbmig) Repair(args []string) error {
+	if len(args) == 0 || args[0] != "repair" {
+		return fmt.Errorf("Invalid call %v", args)
+	}
+
+	var applyMissing, yes bool
+	fs := flag.NewFlagSet("repair", flag.ContinueOnError)
+	fs.BoolVar(&applyMissing, "apply-missing", false, "Apply the migrations missing from the history")
+	fs.BoolVar(&yes, "yes", false, "Apply them without asking")
+
+	if _, err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+
+	if err := requireTrackingTable(d); err != nil {
+		return err
+	}
+
+	release, err := acquireLock(d)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	pending, applied, err := pendingMigrations(d)
+	if err != nil {
+		return err
+	}
+
+	missing := outOfOrder(pending, applied)
+	if len(missing) == 0 {
+		d.printf("Nothing to repair, no pending migration is older than the newest applied one\n")
+		return nil
+	}
+
+	d.printf("%d migrations are older than the newest applied one but were never applied:\n", len(missing))
+	for _, fname := range missing {
+		d.printf("  %s\n", fname)
+	}
+
+	if !applyMissing {
+		d.printf("Run `%s repair --apply-missing` to apply them\n", programName)
+		return nil
+	}
+	if !yes {
+		ok, err := confirm(d, fmt.Sprintf("Apply these %d migrations?", len(missing)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			d.printf("Nothing applied\n")
+			return nil
+		}
+	}
+
+	warnNonTransactionalDDL(d)
+	for _, fname := range missing {
+		if err := runMigration(d, fname, "up"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
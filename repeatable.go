@@ -0,0 +1,73 @@
+package main
+
+import (
+	"path"
+	"strings"
+)
+
+// repeatablePrefix marks migrations that are re-applied whenever they
+// change, like views and stored procedures, rather than applied once.
+const repeatablePrefix string = "R__"
+
+func (m *migration) repeatable() bool {
+	_, ok := m.directive("repeatable")
+	return ok || strings.HasPrefix(path.Base(m.Name), repeatablePrefix)
+}
+
+// isRepeatable tells whether the migration file is repeatable, by its name
+// or by a `-- dbmi:repeatable` header.
+func isRepeatable(d *Dbmig, fname string) bool {
+	if strings.HasPrefix(path.Base(fname), repeatablePrefix) {
+		return true
+	}
+	if isGoMigration(fname) {
+		return false
+	}
+
+	data, err := readMigrationFile(d, fname)
+	if err != nil {
+		return false
+	}
+	_, ok := parseDirectives(data)["repeatable"]
+
+	return ok
+}
+
+// withoutRepeatables drops repeatable migrations, which aren't part of the
+// versioned history, from names.
+func withoutRepeatables(d *Dbmig, names []string) []string {
+	result := make([]string, 0, len(names))
+	for _, fname := range names {
+		if !isRepeatable(d, fname) {
+			result = append(result, fname)
+		}
+	}
+
+	return result
+}
+
+// pendingRepeatables returns the repeatable migrations that were never
+// applied or whose contents changed since they were last applied.
+func pendingRepeatables(d *Dbmig) ([]string, error) {
+	checksums, err := storedChecksums(d)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make([]string, 0)
+	for _, fname := range migrationFilenames(d.config) {
+		if !isRepeatable(d, fname) {
+			continue
+		}
+
+		data, err := readMigrationFile(d, fname)
+		if err != nil {
+			return nil, err
+		}
+		if checksums[fname] != migrationChecksum(data) {
+			pending = append(pending, fname)
+		}
+	}
+
+	return pending, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const defaultRetryBackoff time.Duration = time.Second
+
+// retryableError reports whether err is a serialization failure or a
+// deadlock, after which the migration can simply be run again.
+func retryableError(err error) bool {
+	for ; err != nil; err = errors.Unwrap(err) {
+		switch sqlState(err) {
+		case "40001", "40P01":
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Config) retryBackoff() time.Duration {
+	if c.RetryBackoff == "" {
+		return defaultRetryBackoff
+	}
+
+	backoff, err := time.ParseDuration(c.RetryBackoff)
+	if err != nil {
+		return defaultRetryBackoff
+	}
+
+	return backoff
+}
+
+func validateRetries(c *Config) error {
+	if c.Retries < 0 {
+		return fmt.Errorf("db_retries must not be negative")
+	}
+
+	if c.RetryBackoff != "" {
+		if _, err := parseTimeout(c.RetryBackoff); err != nil {
+			return fmt.Errorf("db_retry_backoff: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// withRetries calls apply, and again up to db_retries times, waiting twice
+// as long each time from db_retry_backoff on, while it fails with a
+// retryable error. A failed migration is only retried when its transaction
+// undid it entirely, which rules out --all-in-one-tx runs.
+func withRetries(d *Dbmig, fname string, apply func() error) error {
+	backoff := d.config.retryBackoff()
+	for attempt := 0; ; attempt++ {
+		err := apply()
+		if err == nil || attempt >= d.config.Retries || !retryableError(err) {
+			return err
+		}
+		if d.opts.sharedTx != nil || !d.dialect().transactionalDDL {
+			return err
+		}
+
+		d.logf("Migration %s hit a deadlock or serialization failure, retrying in %s (%d of %d): %v", fname, backoff, attempt+1, d.config.Retries, err)
+		select {
+		case <-time.After(backoff):
+		case <-d.context().Done():
+			return err
+		}
+		backoff *= 2
+	}
+}
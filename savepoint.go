@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const savepointName string = "dbmi_statement"
+
+// execStatementSavepoint runs stmt within a savepoint, so that when it fails
+// only stmt is undone and the transaction stays usable.
+func execStatementSavepoint(ctx context.Context, d *Dbmig, tx *sql.Tx, stmt string, timeout time.Duration) error {
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT "+savepointName); err != nil {
+		return err
+	}
+
+	if err := execStatement(ctx, d, tx, stmt, timeout); err != nil {
+		if _, rerr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+savepointName); rerr != nil {
+			return fmt.Errorf("%v (rolling back to the savepoint failed too: %v)", err, rerr)
+		}
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT "+savepointName)
+	return err
+}
+
+// runStatement runs statement number index, of count or of an unknown
+// number when count is 0, of a migration section. With --savepoints it runs
+// in a savepoint and, with --skip-failed-statements, a failure is logged and
+// skipped.
+func runStatement(ctx context.Context, d *Dbmig, tx *sql.Tx, fname string, direction string, stmt string, index int, count int, timeout time.Duration) error {
+	var err error
+	if d.opts.savepoints {
+		err = execStatementSavepoint(ctx, d, tx, stmt, timeout)
+	} else {
+		err = execStatement(ctx, d, tx, stmt, timeout)
+	}
+	if err == nil {
+		return nil
+	}
+
+	if d.opts.savepoints && d.opts.skipFailedStatements && ctx.Err() == nil {
+		d.logf("Warning: skipping statement %d of %s, which failed: %v\n%s", index, fname, err, d.config.loggedStatement(stmt))
+		return nil
+	}
+
+	d.logf("Error Applying migration: %v\n", err)
+	return &migrationError{Name: fname, Direction: direction, Statement: stmt, Shown: d.config.loggedStatement(stmt), Index: index, Count: count, Verbose: d.opts.verboseErrors, Err: err}
+}
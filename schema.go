@@ -0,0 +1,210 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+type schemaColumn struct {
+	Name    string
+	Type    string
+	NotNull bool
+	Default string
+}
+
+type schemaTable struct {
+	Name        string
+	Columns     []schemaColumn
+	Constraints []string
+	Indexes     []string
+}
+
+const (
+	schemaColumnsQuery string = `SELECT c.relname, a.attname, format_type(a.atttypid, a.atttypmod), a.attnotnull,
+		COALESCE(pg_get_expr(ad.adbin, ad.adrelid), '')
+	FROM pg_attribute a
+	JOIN pg_class c ON c.oid = a.attrelid
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	LEFT JOIN pg_attrdef ad ON ad.adrelid = a.attrelid AND ad.adnum = a.attnum
+	WHERE n.nspname = $1 AND c.relkind = 'r' AND a.attnum > 0 AND NOT a.attisdropped
+	ORDER BY c.relname, a.attnum`
+
+	schemaConstraintsQuery string = `SELECT c.relname, con.conname, pg_get_constraintdef(con.oid)
+	FROM pg_constraint con
+	JOIN pg_class c ON c.oid = con.conrelid
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	WHERE n.nspname = $1 AND c.relkind = 'r'
+	ORDER BY c.relname, con.conname`
+
+	// Indexes backing a constraint are already covered by the constraint.
+	schemaIndexesQuery string = `SELECT t.relname, pg_get_indexdef(i.indexrelid)
+	FROM pg_index i
+	JOIN pg_class t ON t.oid = i.indrelid
+	JOIN pg_class ic ON ic.oid = i.indexrelid
+	JOIN pg_namespace n ON n.oid = t.relnamespace
+	WHERE n.nspname = $1
+		AND NOT EXISTS (SELECT 1 FROM pg_constraint con WHERE con.conindid = i.indexrelid)
+	ORDER BY t.relname, ic.relname`
+)
+
+// schemaName is the schema holding the migrated objects.
+func (c *Config) schemaName() string {
+	if c.Schema != "" {
+		return c.Schema
+	}
+
+	return "public"
+}
+
+// dumpSchema reads the tables of the migrated schema from the catalog,
+// leaving out the tracking table.
+func dumpSchema(d *Dbmig) ([]*schemaTable, error) {
+	tables := make([]*schemaTable, 0)
+	byName := map[string]*schemaTable{}
+	schema := d.config.schemaName()
+
+	table := func(name string) *schemaTable {
+		t, ok := byName[name]
+		if !ok {
+			t = &schemaTable{Name: name}
+			byName[name] = t
+			tables = append(tables, t)
+		}
+		return t
+	}
+
+	ctx, cancel := d.statementContext()
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, schemaColumnsQuery, schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tname string
+		var col schemaColumn
+		if err := rows.Scan(&tname, &col.Name, &col.Type, &col.NotNull, &col.Default); err != nil {
+			return nil, err
+		}
+		if tname == d.config.Tablename {
+			continue
+		}
+		t := table(tname)
+		t.Columns = append(t.Columns, col)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = d.db.QueryContext(ctx, schemaConstraintsQuery, schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tname, name, def string
+		if err := rows.Scan(&tname, &name, &def); err != nil {
+			return nil, err
+		}
+		if t, ok := byName[tname]; ok {
+			t.Constraints = append(t.Constraints, fmt.Sprintf("CONSTRAINT %s %s", name, def))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = d.db.QueryContext(ctx, schemaIndexesQuery, schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tname, def string
+		if err := rows.Scan(&tname, &def); err != nil {
+			return nil, err
+		}
+		if t, ok := byName[tname]; ok {
+			t.Indexes = append(t.Indexes, def)
+		}
+	}
+
+	return tables, rows.Err()
+}
+
+func (c schemaColumn) String() string {
+	s := fmt.Sprintf("%s %s", c.Name, c.Type)
+	if c.NotNull {
+		s += " NOT NULL"
+	}
+	if c.Default != "" {
+		s += " DEFAULT " + c.Default
+	}
+
+	return s
+}
+
+// formatSchema renders tables as CREATE statements in a stable order, so
+// dumps of the same schema are identical.
+func formatSchema(tables []*schemaTable) string {
+	var b strings.Builder
+	for _, t := range tables {
+		lines := make([]string, 0, len(t.Columns)+len(t.Constraints))
+		for _, c := range t.Columns {
+			lines = append(lines, "\t"+c.String())
+		}
+		for _, c := range t.Constraints {
+			lines = append(lines, "\t"+c)
+		}
+
+		fmt.Fprintf(&b, "CREATE TABLE %s (\n%s\n);\n", t.Name, strings.Join(lines, ",\n"))
+		for _, idx := range t.Indexes {
+			fmt.Fprintf(&b, "%s;\n", idx)
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+func (d *Dbmig) DumpSchema(args []string) error {
+	if len(args) == 0 || args[0] != "dump-schema" {
+		return fmt.Errorf("Invalid call %v", args)
+	}
+
+	var output string
+	fs := flag.NewFlagSet("dump-schema", flag.ContinueOnError)
+	fs.StringVar(&output, "output", "schema.sql", "Write the schema to <file>, - for stdout")
+
+	if _, err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+
+	if !d.config.isPostgres() {
+		return fmt.Errorf("dump-schema is only supported on postgres")
+	}
+
+	tables, err := dumpSchema(d)
+	if err != nil {
+		return err
+	}
+
+	dump := formatSchema(tables)
+	if output == "-" {
+		fmt.Fprint(d.stdout(), dump)
+		return nil
+	}
+
+	if err := ioutil.WriteFile(output, []byte(dump), 0644); err != nil {
+		return err
+	}
+	d.printf("Schema of %d tables written to %s\n", len(tables), output)
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// serveRefused are the commands that make no sense within serve.
+var serveRefused = map[string]bool{
+	"serve": true,
+	"watch": true,
+}
+
+// Serve keeps the connection open and runs the commands read from stdin, one
+// per line, saving scripts that run several commands the reconnects. Each
+// command is followed by a line `ok` or `error: <message>`. It stops at the
+// end of stdin or on `quit`.
+func (d *Dbmig) Serve(args []string) error {
+	if len(args) == 0 || args[0] != "serve" {
+		return fmt.Errorf("Invalid call %v", args)
+	}
+
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	if _, err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+
+	d.logf("Serving commands from stdin, one per line, until quit")
+
+	for {
+		line, err := stdin.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		done := err == io.EOF
+
+		line = strings.TrimSpace(line)
+		if line == "quit" || line == "exit" {
+			return nil
+		}
+		if line != "" && !strings.HasPrefix(line, "#") {
+			d.serveCommand(line)
+		}
+
+		if done || d.context().Err() != nil {
+			return d.context().Err()
+		}
+	}
+}
+
+// serveCommand runs a single command line of serve and reports its outcome.
+func (d *Dbmig) serveCommand(line string) {
+	args, err := splitCommandLine(line)
+	if err == nil && serveRefused[args[0]] {
+		err = fmt.Errorf("%s can't run within serve", args[0])
+	}
+	if err == nil {
+		// Flags of one command must not leak into the next.
+		copied := *d
+		copied.opts = runOptions{}
+		err = runCommand(&copied, args)
+	}
+
+	if err != nil {
+		d.printf("error: %s\n", strings.Replace(err.Error(), "\n", " ", -1))
+		return
+	}
+	d.printf("ok\n")
+}
+
+// splitCommandLine splits line into arguments at spaces, keeping the spaces
+// within single or double quotes like a shell.
+func splitCommandLine(line string) ([]string, error) {
+	args := make([]string, 0)
+	var arg strings.Builder
+	inArg := false
+	var quote rune
+
+	for _, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			arg.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("Unterminated %c quote", quote)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+
+	return args, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// With db_soft_delete, migrating down marks the tracking row of a migration
+// rolled back instead of deleting it, so the table keeps every up and down.
+// Applied migrations are then the rows that aren't rolled back, and the
+// unique index on names only covers those.
+
+// appliedCondition is what tells the rows of applied migrations apart under
+// db_soft_delete.
+const appliedCondition string = "rolled_back_at IS NULL"
+
+// whereApplied returns a WHERE clause of conds, with appliedCondition added
+// under db_soft_delete, or "" when there is nothing to add.
+func (c *Config) whereApplied(conds ...string) string {
+	if c.SoftDelete {
+		conds = append(conds, appliedCondition)
+	}
+	if len(conds) == 0 {
+		return ""
+	}
+
+	return " WHERE " + strings.Join(conds, " AND ")
+}
+
+// trackingIndexName returns the unquoted name of the unique index on the
+// names of the tracking table, which differs under db_soft_delete so
+// switching rebuilds it.
+func (c *Config) trackingIndexName(soft bool) string {
+	if soft {
+		return c.Tablename + "_applied_name_key"
+	}
+
+	return c.Tablename + "_name_key"
+}
+
+// qualifiedIndex quotes an index name, with the schema of the tracking table
+// it lives in, for DROP INDEX.
+func (c *Config) qualifiedIndex(name string) string {
+	quote := c.dialect().quoteIdent
+	if c.Schema != "" {
+		return fmt.Sprintf("%s.%s", quote(c.Schema), quote(name))
+	}
+
+	return quote(name)
+}
+
+// unrecordStatement returns the statement undoing the tracking of the
+// migration named by $1: a DELETE, or under db_soft_delete an UPDATE marking
+// its row rolled back.
+func (c *Config) unrecordStatement() string {
+	if c.SoftDelete {
+		return fmt.Sprintf(`UPDATE %s SET rolled_back_at = CURRENT_TIMESTAMP WHERE name = $1 AND %s`, c.trackingTable(), appliedCondition)
+	}
+
+	return fmt.Sprintf(`DELETE FROM %s WHERE name = $1`, c.trackingTable())
+}
+
+// checkNoRolledBackRows makes init fail when db_soft_delete was turned off
+// while rolled back rows are left, which would count as applied again.
+func checkNoRolledBackRows(ctx context.Context, d *Dbmig) error {
+	if d.config.SoftDelete {
+		return nil
+	}
+
+	var n int
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE rolled_back_at IS NOT NULL", d.config.trackingTable())
+	if err := d.db.QueryRowContext(ctx, query).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return fmt.Errorf("%s has %d rows of rolled back migrations, kept under db_soft_delete. Turn db_soft_delete back on, or delete them first", d.config.trackingTableName(), n)
+	}
+
+	return nil
+}
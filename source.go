@@ -0,0 +1,156 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// resolveFolder makes the migrations folder available on the local disk.
+// db_dbmi_folder may be a plain path, a file:// URL, or an http(s):// URL of
+// a tarball of the migrations, which is downloaded and unpacked into a
+// temporary directory. The returned function removes that directory.
+func resolveFolder(ctx context.Context, c *Config) (func(), error) {
+	u, err := url.Parse(c.Folder)
+	if err != nil || u.Scheme == "" || len(u.Scheme) == 1 {
+		// A plain path, possibly with a Windows drive letter.
+		return func() {}, nil
+	}
+
+	switch u.Scheme {
+	case "file":
+		c.Folder = u.Path
+		return func() {}, nil
+	case "http", "https":
+		dir, err := ioutil.TempDir("", "dbmi-migrations-")
+		if err != nil {
+			return nil, err
+		}
+		cleanup := func() { os.RemoveAll(dir) }
+
+		root, err := fetchTarball(ctx, c.Folder, dir)
+		if err != nil {
+			cleanup()
+			return nil, fmt.Errorf("Fetching migrations from %s: %v", c.Folder, err)
+		}
+
+		c.logf("Fetched migrations from %s into %s", c.Folder, root)
+		c.source = c.Folder
+		c.Folder = root
+		return cleanup, nil
+	}
+
+	return nil, fmt.Errorf("Unsupported db_dbmi_folder scheme %q, expected a path, file://, http:// or https://", u.Scheme)
+}
+
+// remoteFolder reports whether the migrations were fetched from a URL, in
+// which case the local copy is temporary and must not be written to.
+func (c *Config) remoteFolder() bool {
+	return c.source != ""
+}
+
+// fetchTarball downloads a tar or tar.gz archive and extracts the regular
+// files in it into dir, keeping their paths, and returns the folder the
+// migrations are read from. Migrations in subfolders are named by their path,
+// so the paths in the archive are kept for them to be tracked as when the
+// folder is read locally. When everything in the archive is under a single
+// top directory, like in `tar czf migrations.tgz migrations`, that directory
+// is the migrations folder.
+func fetchTarball(ctx context.Context, rawurl string, dir string) (string, error) {
+	if err := extractTarball(ctx, rawurl, dir); err != nil {
+		return "", err
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		return filepath.Join(dir, entries[0].Name()), nil
+	}
+
+	return dir, nil
+}
+
+func extractTarball(ctx context.Context, rawurl string, dir string) error {
+	req, err := http.NewRequest(http.MethodGet, rawurl, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var body io.Reader = resp.Body
+	if isGzipped(rawurl, resp.Header.Get("Content-Type")) {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	seen := map[string]bool{}
+	tr := tar.NewReader(body)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := path.Clean(hdr.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("the archive contains %s, outside of its root", hdr.Name)
+		}
+		if seen[name] {
+			return fmt.Errorf("the archive contains %s more than once", name)
+		}
+		seen[name] = true
+
+		fpath := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fpath), 0755); err != nil {
+			return err
+		}
+		f, err := os.Create(fpath)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, tr); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func isGzipped(rawurl string, contentType string) bool {
+	p := strings.SplitN(rawurl, "?", 2)[0]
+	return strings.HasSuffix(p, ".gz") || strings.HasSuffix(p, ".tgz") || strings.Contains(contentType, "gzip")
+}
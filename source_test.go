@@ -0,0 +1,71 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func tarballServer(t *testing.T, files map[string]string) *httptest.Server {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, data := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(buf.Bytes())
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestRemoteFolderKeepsSubfolders(t *testing.T) {
+	for _, root := range []string{"", "migrations/"} {
+		t.Run(fmt.Sprintf("root %q", root), func(t *testing.T) {
+			srv := tarballServer(t, map[string]string{
+				root + "1600000000_a.sql":           "SELECT 1;\n/*DOWN*/\n",
+				root + "users/1600000100_users.sql": "SELECT 1;\n/*DOWN*/\n",
+			})
+
+			c := defaultConfig()
+			c.Folder = srv.URL + "/migrations.tar"
+			cleanup, err := resolveFolder(context.Background(), c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cleanup()
+
+			want := "[1600000000_a.sql users/1600000100_users.sql]"
+			if got := migrationFilenames(c); fmt.Sprint(got) != want {
+				t.Errorf("migrations %v, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestRemoteFolderRejectsPathsOutsideTheArchive(t *testing.T) {
+	srv := tarballServer(t, map[string]string{"../1600000000_a.sql": "SELECT 1;\n/*DOWN*/\n"})
+
+	c := defaultConfig()
+	c.Folder = srv.URL + "/migrations.tar"
+	if cleanup, err := resolveFolder(context.Background(), c); err == nil {
+		cleanup()
+		t.Fatal("an archive with a path outside of its root was extracted")
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// statementScanner splits SQL into statements at the semicolons outside of
+// quotes, dollar quotes and comments, reading its input as it goes so large
+// files don't have to be loaded in memory. It also reports the down
+// separator.
+type statementScanner struct {
+	r *bufio.Reader
+	// prev and beforePrev are the last runes read, to tell `$tag$` quotes
+	// and E'' strings apart from identifiers containing $ or ending in e.
+	prev, beforePrev rune
+}
+
+func newStatementScanner(r io.Reader) *statementScanner {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+
+	return &statementScanner{r: br}
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func (s *statementScanner) saw(r rune) {
+	s.beforePrev, s.prev = s.prev, r
+}
+
+func (s *statementScanner) read() (rune, error) {
+	r, _, err := s.r.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+
+	return r, nil
+}
+
+// peekIs tells whether the input continues with want, without consuming it.
+func (s *statementScanner) peekIs(want string) bool {
+	b, err := s.r.Peek(len(want))
+	return err == nil && string(b) == want
+}
+
+func (s *statementScanner) skip(n int) {
+	s.r.Discard(n)
+}
+
+// next returns the next statement, trimmed and without its semicolon. At the
+// down separator it returns the statement before it, if any, with separator
+// set. It returns io.EOF once the input is exhausted.
+func (s *statementScanner) next() (stmt string, separator bool, err error) {
+	var b strings.Builder
+	for {
+		r, err := s.read()
+		if err == io.EOF {
+			stmt = strings.TrimSpace(b.String())
+			if stmt == "" {
+				return "", false, io.EOF
+			}
+			return stmt, false, nil
+		}
+		if err != nil {
+			return "", false, err
+		}
+
+		switch {
+		case r == ';':
+			s.saw(r)
+			if stmt = strings.TrimSpace(b.String()); stmt != "" {
+				return stmt, false, nil
+			}
+			b.Reset()
+			continue
+		case r == '/' && s.peekIs(migrationSeparator[1:]):
+			s.skip(len(migrationSeparator) - 1)
+			s.saw(' ')
+			return strings.TrimSpace(b.String()), true, nil
+		case r == '\'':
+			escapes := (s.prev == 'e' || s.prev == 'E') && !isIdentRune(s.beforePrev)
+			b.WriteRune(r)
+			if err := s.quoted(&b, '\'', escapes); err != nil {
+				return "", false, err
+			}
+		case r == '"':
+			b.WriteRune(r)
+			if err := s.quoted(&b, '"', false); err != nil {
+				return "", false, err
+			}
+		case r == '-' && s.peekIs("-"):
+			b.WriteRune(r)
+			if err := s.lineComment(&b); err != nil {
+				return "", false, err
+			}
+		case r == '/' && s.peekIs("*"):
+			b.WriteRune(r)
+			if err := s.blockComment(&b); err != nil {
+				return "", false, err
+			}
+		case r == '$' && !isIdentRune(s.prev):
+			b.WriteRune(r)
+			if err := s.dollarQuoted(&b); err != nil {
+				return "", false, err
+			}
+		default:
+			b.WriteRune(r)
+		}
+		s.saw(r)
+	}
+}
+
+// quoted copies a quoted string or identifier after its opening quote. A
+// doubled quote stays inside; with escapes, so does a backslash escaped one.
+func (s *statementScanner) quoted(b *strings.Builder, quote rune, escapes bool) error {
+	for {
+		r, err := s.read()
+		if err != nil {
+			return eofIsUnexpected(err)
+		}
+		b.WriteRune(r)
+
+		if escapes && r == '\\' {
+			next, err := s.read()
+			if err != nil {
+				return eofIsUnexpected(err)
+			}
+			b.WriteRune(next)
+			continue
+		}
+		if r == quote {
+			if s.peekIs(string(quote)) {
+				s.skip(1)
+				b.WriteRune(quote)
+				continue
+			}
+			s.saw(r)
+			return nil
+		}
+	}
+}
+
+func (s *statementScanner) lineComment(b *strings.Builder) error {
+	for {
+		r, err := s.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		b.WriteRune(r)
+		if r == '\n' {
+			s.saw(r)
+			return nil
+		}
+	}
+}
+
+// blockComment copies a comment after its opening slash. Like Postgres,
+// block comments nest.
+func (s *statementScanner) blockComment(b *strings.Builder) error {
+	s.skip(1)
+	b.WriteRune('*')
+
+	depth := 1
+	for depth > 0 {
+		r, err := s.read()
+		if err != nil {
+			return eofIsUnexpected(err)
+		}
+		b.WriteRune(r)
+
+		switch {
+		case r == '/' && s.peekIs("*"):
+			s.skip(1)
+			b.WriteRune('*')
+			depth++
+		case r == '*' && s.peekIs("/"):
+			s.skip(1)
+			b.WriteRune('/')
+			depth--
+		}
+	}
+	s.saw(' ')
+
+	return nil
+}
+
+// dollarQuoted copies a $tag$...$tag$ string after its first dollar. A $
+// that doesn't open a dollar quote, like the one in $1, is left as is.
+func (s *statementScanner) dollarQuoted(b *strings.Builder) error {
+	var tag strings.Builder
+	for i := 0; ; i++ {
+		peek, err := s.r.Peek(i + 1)
+		if err != nil {
+			return nil
+		}
+		c := rune(peek[i])
+		if c == '$' {
+			break
+		}
+		if !(c == '_' || unicode.IsLetter(c) || (i > 0 && unicode.IsDigit(c))) {
+			return nil
+		}
+		tag.WriteRune(c)
+	}
+
+	delimiter := "$" + tag.String() + "$"
+	s.skip(len(delimiter) - 1)
+	b.WriteString(delimiter[1:])
+
+	for {
+		r, err := s.read()
+		if err != nil {
+			return eofIsUnexpected(err)
+		}
+		b.WriteRune(r)
+		if r == '$' && s.peekIs(delimiter[1:]) {
+			s.skip(len(delimiter) - 1)
+			b.WriteString(delimiter[1:])
+			s.saw('$')
+			return nil
+		}
+	}
+}
+
+func eofIsUnexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+// splitStatements splits a section of a migration into its statements.
+func splitStatements(sql string) ([]string, error) {
+	s := newStatementScanner(strings.NewReader(sql))
+
+	stmts := make([]string, 0)
+	for {
+		stmt, _, err := s.next()
+		if err == io.EOF {
+			return stmts, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+}
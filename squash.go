@@ -0,0 +1,255 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Squash collapses the migrations from the first one through --to into a
+// single migration named --name. The new file takes the timestamp of the
+// target so it sorts in its place. When all squashed migrations are applied
+// it offers to baseline: replace their tracking rows with one for the new
+// migration and remove the old files.
+func (d *Dbmig) Squash(args []string) error {
+	if len(args) == 0 || args[0] != "squash" {
+		return fmt.Errorf("Invalid call %v", args)
+	}
+
+	var to, name string
+	var yes bool
+	fs := flag.NewFlagSet("squash", flag.ContinueOnError)
+	fs.StringVar(&to, "to", "", "Last migration <filename> to squash")
+	fs.StringVar(&name, "name", "", "Name of the squashed migration")
+	fs.BoolVar(&yes, "yes", false, "Baseline without asking")
+
+	if _, err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+
+	if to == "" || name == "" {
+		return fmt.Errorf("Usage: %s squash --to <filename> --name <name>", programName)
+	}
+
+	if d.config.remoteFolder() {
+		return fmt.Errorf("Cannot squash migrations fetched from %s", d.config.source)
+	}
+
+	names := withoutRepeatables(d, migrationFilenames(d.config))
+
+	i := indexOf(names, to)
+	if i < 0 {
+		return fmt.Errorf("Migration %s not found in %s", to, d.config.Folder)
+	}
+	squashed := names[:i+1]
+
+	ts, ok := migrationTime(to)
+	if !ok {
+		return fmt.Errorf("Migration %s has no timestamp prefix", to)
+	}
+	slug := migrationSlug(name)
+	if slug == "" {
+		return fmt.Errorf("Migration name %q has no letters or digits to name the file after", name)
+	}
+	fullName := fmt.Sprintf("%d_%s.sql", ts.Unix(), slug)
+	if indexOf(squashed, fullName) >= 0 {
+		return fmt.Errorf("Squashed migration %s would overwrite an existing migration", fullName)
+	}
+
+	squashedSet := toSet(squashed)
+	irreversible := false
+	ups := make([]string, 0, len(squashed))
+	downs := make([]string, 0, len(squashed))
+	for _, fname := range squashed {
+		if isGoMigration(fname) {
+			return fmt.Errorf("Migration %s is written in Go and can't be squashed", fname)
+		}
+
+		data, err := readMigrationFile(d, fname)
+		if err != nil {
+			return err
+		}
+
+		m, ok := parseMigration(fname, data)
+		if !ok {
+			return fmt.Errorf("Migration %s has no %s separator", fname, migrationSeparator)
+		}
+
+		if err := checkSquashDirectives(m, squashedSet); err != nil {
+			return err
+		}
+		irreversible = irreversible || m.irreversible()
+
+		ups = append(ups, fmt.Sprintf("-- squashed: %s\n%s", fname, withoutDirectives(m.Up)))
+		downs = append([]string{fmt.Sprintf("-- squashed: %s\n%s", fname, withoutDirectives(m.Down))}, downs...)
+	}
+
+	content := strings.Join(ups, "\n\n") + "\n\n" + migrationSeparator + "\n\n" + strings.Join(downs, "\n\n") + "\n"
+	if irreversible {
+		content = directivePrefix + "irreversible\n" + content
+	}
+	fullPath := fmt.Sprintf("%s/%s", d.config.Folder, fullName)
+
+	applied, err := appliedMigrations(d.context(), d, -1, false)
+	if err != nil {
+		return err
+	}
+
+	appliedSet := toSet(applied)
+	appliedCount := 0
+	for _, fname := range squashed {
+		if appliedSet[fname] {
+			appliedCount++
+		}
+	}
+
+	// Unless none of them is applied, the squashed migration would be
+	// pending and run again what is already applied, so it is only written
+	// along with the baseline.
+	if appliedCount == 0 {
+		if err := ioutil.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			return err
+		}
+		d.printf("Squashed %d migrations into %s. None of them is applied, remove them once %s replaces them.\n", len(squashed), fullPath, fullName)
+		return nil
+	}
+	if appliedCount < len(squashed) {
+		return fmt.Errorf("%d of the %d migrations through %s are applied, so the squashed migration could neither be baselined nor run. Migrate up through %s first", appliedCount, len(squashed), to, to)
+	}
+
+	if !yes {
+		ok, err := confirm(d, fmt.Sprintf("Squash into %s, baseline it as applied and remove the %d squashed files?", fullName, len(squashed)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			d.printf("Nothing squashed\n")
+			return nil
+		}
+	}
+
+	release, err := acquireLock(d)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if err := ioutil.WriteFile(fullPath, []byte(content), 0644); err != nil {
+		return err
+	}
+	if err := baseline(d, fullName, migrationChecksum(content), squashed); err != nil {
+		os.Remove(fullPath)
+		return err
+	}
+	d.printf("Squashed %d migrations into %s\n", len(squashed), fullPath)
+
+	// The squashed migrations are no longer tracked, so the pending ones
+	// requiring them now require their replacement.
+	mapping := map[string]string{}
+	for _, fname := range squashed {
+		mapping[fname] = fullName
+	}
+	for _, fname := range migrationFilenames(d.config) {
+		if appliedSet[fname] || squashedSet[fname] || fname == fullName || isGoMigration(fname) {
+			continue
+		}
+		if err := renameRequires(d, fname, mapping); err != nil {
+			return err
+		}
+	}
+
+	for _, fname := range squashed {
+		if err := os.Remove(fmt.Sprintf("%s/%s", d.config.Folder, fname)); err != nil {
+			return err
+		}
+		if isSplitMigration(fname) {
+			if err := os.Remove(fmt.Sprintf("%s/%s", d.config.Folder, downFileFor(fname))); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	d.logf("Baselined %s and removed %d squashed files", fullName, len(squashed))
+
+	return nil
+}
+
+// checkSquashDirectives refuses to squash a migration whose directives
+// would apply to the whole squashed migration. Requirements among the
+// squashed migrations are met by their order and irreversible carries over,
+// so only those can be merged.
+func checkSquashDirectives(m *migration, squashed map[string]bool) error {
+	keys := make([]string, 0, len(m.directives))
+	for key := range m.directives {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		switch key {
+		case "irreversible":
+		case "requires":
+			for _, req := range m.directives[key] {
+				if req != "" && !squashed[req] {
+					return fmt.Errorf("Migration %s requires %s, which isn't squashed with it", m.Name, req)
+				}
+			}
+		default:
+			return fmt.Errorf("Migration %s has a %s%s directive, which would apply to the whole squashed migration. Squash only the migrations before it", m.Name, directivePrefix, key)
+		}
+	}
+
+	return nil
+}
+
+// withoutDirectives returns a section without its directive lines, trimmed.
+func withoutDirectives(section string) string {
+	lines := make([]string, 0)
+	for _, line := range strings.Split(section, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), directivePrefix) {
+			lines = append(lines, line)
+		}
+	}
+
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// baseline replaces the tracking rows of the squashed migrations with a
+// single row for their replacement. It takes the oldest version among them,
+// so the migrations applied after the squashed ones stay newer.
+func baseline(d *Dbmig, fname string, checksum string, squashed []string) error {
+	ctx, cancel := d.statementContext()
+	defer cancel()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	table := d.config.trackingTable()
+	versionQuery := d.rebind(fmt.Sprintf(`SELECT version FROM %s%s`, table, d.config.whereApplied("name = $1")))
+	var oldest int64
+	for i, old := range squashed {
+		var version int64
+		if err := tx.QueryRowContext(ctx, versionQuery, old).Scan(&version); err != nil {
+			return fmt.Errorf("Reading the version of %s: %v", old, err)
+		}
+		if i == 0 || version < oldest {
+			oldest = version
+		}
+
+		if _, err := tx.ExecContext(ctx, d.rebind(d.config.unrecordStatement()), old); err != nil {
+			return err
+		}
+	}
+
+	stmt := fmt.Sprintf(`INSERT INTO %s (name, version, checksum) VALUES ($1, $2, $3)`, table)
+	if _, err := tx.ExecContext(ctx, d.rebind(stmt), fname, oldest, checksum); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,107 @@
+//go:build sqlite
+// +build sqlite
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var squashMigrations = map[string]string{
+	"1600000000_a.sql": "CREATE TABLE a (id INTEGER);\n/*DOWN*/\nDROP TABLE a;\n",
+	"1600000100_b.sql": "CREATE TABLE b (id INTEGER);\n/*DOWN*/\nDROP TABLE b;\n",
+	"1600000200_c.sql": "CREATE TABLE c (id INTEGER);\n/*DOWN*/\nDROP TABLE c;\n",
+}
+
+func TestSquashBaselinesAtTheOldestVersion(t *testing.T) {
+	for _, soft := range []bool{false, true} {
+		t.Run(fmt.Sprintf("soft delete %v", soft), func(t *testing.T) {
+			d := newTestDbmig(t, squashMigrations)
+			d.config.SoftDelete = soft
+			mustRun(t, d, "init")
+			mustRun(t, d, "migrate up all")
+
+			mustRun(t, d, "squash --to 1600000100_b.sql --name base --yes")
+
+			want := "[1600000100_base.sql 1600000200_c.sql]"
+			if got := appliedNames(t, d); fmt.Sprint(got) != want {
+				t.Errorf("applied %v, want %s", got, want)
+			}
+			for _, fname := range []string{"1600000000_a.sql", "1600000100_b.sql"} {
+				if _, err := os.Stat(filepath.Join(d.config.Folder, fname)); !os.IsNotExist(err) {
+					t.Errorf("%s was not removed: %v", fname, err)
+				}
+			}
+
+			// The newest migration is still the one applied after the
+			// squashed ones.
+			mustRun(t, d, "migrate down 1")
+			if got := appliedNames(t, d); fmt.Sprint(got) != "[1600000100_base.sql]" {
+				t.Errorf("after migrate down 1, applied %v", got)
+			}
+
+			var rows int
+			d.db.QueryRow(`SELECT COUNT(*) FROM db_migrations WHERE name = '1600000000_a.sql'`).Scan(&rows)
+			if soft && rows != 1 {
+				t.Errorf("under db_soft_delete, the row of a squashed migration is gone")
+			}
+			if !soft && rows != 0 {
+				t.Errorf("the row of a squashed migration is left")
+			}
+		})
+	}
+}
+
+func TestSquashRefusesPartlyApplied(t *testing.T) {
+	d := newTestDbmig(t, squashMigrations)
+	mustRun(t, d, "migrate up 1")
+
+	if err := runCommand(d, []string{"squash", "--to", "1600000100_b.sql", "--name", "base", "--yes"}); err == nil {
+		t.Fatal("squashing partly applied migrations succeeded")
+	}
+	if _, err := os.Stat(filepath.Join(d.config.Folder, "1600000100_base.sql")); !os.IsNotExist(err) {
+		t.Errorf("the squashed migration was written: %v", err)
+	}
+}
+
+func TestSquashDirectives(t *testing.T) {
+	d := newTestDbmig(t, map[string]string{
+		"1600000000_a.sql": "-- dbmi:irreversible\nCREATE TABLE a (id INTEGER);\n/*DOWN*/\nDROP TABLE a;\n",
+		"1600000100_b.sql": "-- dbmi:requires: 1600000000_a.sql\nCREATE TABLE b (id INTEGER);\n/*DOWN*/\nDROP TABLE b;\n",
+		"1600000200_c.sql": "-- dbmi:isolation: serializable\nCREATE TABLE c (id INTEGER);\n/*DOWN*/\nDROP TABLE c;\n",
+	})
+
+	if err := runCommand(d, []string{"squash", "--to", "1600000200_c.sql", "--name", "base"}); err == nil || !strings.Contains(err.Error(), "isolation") {
+		t.Errorf("squashing through a migration with an isolation directive: got %v, want a refusal", err)
+	}
+
+	mustRun(t, d, "squash --to 1600000100_b.sql --name base")
+	data, err := ioutil.ReadFile(filepath.Join(d.config.Folder, "1600000100_base.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parseDirectives(string(data)); fmt.Sprint(got) != "map[irreversible:[]]" {
+		t.Errorf("squashed migration has directives %v, want only irreversible", got)
+	}
+}
+
+func TestSquashRewritesPendingRequires(t *testing.T) {
+	d := newTestDbmig(t, squashMigrations)
+	mustRun(t, d, "migrate up all")
+	writeTestMigrations(t, d.config.Folder, map[string]string{
+		"1600000300_d.sql": "-- dbmi:requires: 1600000000_a.sql\nCREATE TABLE d (id INTEGER);\n/*DOWN*/\nDROP TABLE d;\n",
+	})
+
+	mustRun(t, d, "squash --to 1600000100_b.sql --name base --yes")
+	mustRun(t, d, "migrate up all")
+
+	want := "[1600000100_base.sql 1600000200_c.sql 1600000300_d.sql]"
+	if got := appliedNames(t, d); fmt.Sprint(got) != want {
+		t.Errorf("applied %v, want %s", got, want)
+	}
+}
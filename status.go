@@ -0,0 +1,271 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const dateLayout string = "2006-01-02"
+
+// parseDate accepts either an RFC3339 timestamp or a plain YYYY-MM-DD date.
+func parseDate(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	t, err := time.ParseInLocation(dateLayout, s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Invalid date %q, expected RFC3339 or YYYY-MM-DD", s)
+	}
+
+	return t, nil
+}
+
+// migrationTime returns the time encoded in the unix timestamp prefix of a
+// migration filename such as 1600000000_create_items.sql.
+func migrationTime(fname string) (time.Time, bool) {
+	fname = path.Base(fname)
+	i := strings.Index(fname, "_")
+	if i <= 0 {
+		return time.Time{}, false
+	}
+
+	ts, err := strconv.ParseInt(fname[:i], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(ts, 0), true
+}
+
+// migrationTitle turns a migration filename like 1600000000_create_items.sql
+// into "create items" for display. The filename stays the tracking key.
+func migrationTitle(fname string) string {
+	title := strings.TrimSuffix(strings.TrimSuffix(path.Base(fname), upSuffix), ".sql")
+	if _, ok := migrationTime(title); ok {
+		title = title[strings.Index(title, "_")+1:]
+	}
+	title = strings.TrimPrefix(title, repeatablePrefix)
+
+	return strings.TrimSpace(strings.Replace(title, "_", " ", -1))
+}
+
+func (d *Dbmig) Status(args []string) error {
+	if len(args) == 0 || args[0] != "status" {
+		return fmt.Errorf("Invalid call %v", args)
+	}
+
+	var since, before, exclude, sinceVersion string
+	var porcelain bool
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	fs.BoolVar(&porcelain, "porcelain", false, "Print a stable A <name> <applied at>, P <name> and SUMMARY format for scripts")
+	fs.StringVar(&exclude, "exclude", "", "Show the pending migrations matching the glob <pattern> as excluded, like migrate --exclude")
+	fs.StringVar(&since, "since", "", "Only show migrations created at or after <date>")
+	fs.StringVar(&before, "before", "", "Only show migrations created before <date>")
+	fs.StringVar(&sinceVersion, "since-version", "", "Only show the migrations applied after the applied migration <name>, in the order they were applied")
+
+	if _, err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+
+	var sinceTime, beforeTime time.Time
+	var err error
+	if since != "" {
+		if sinceTime, err = parseDate(since); err != nil {
+			return err
+		}
+	}
+	if before != "" {
+		if beforeTime, err = parseDate(before); err != nil {
+			return err
+		}
+	}
+
+	if err := requireTrackingTable(d); err != nil {
+		return err
+	}
+
+	applied, err := appliedMigrations(d.context(), d, -1, false)
+	if err != nil {
+		return err
+	}
+	appliedSet := toSet(applied)
+
+	changed, err := pendingRepeatables(d)
+	if err != nil {
+		return err
+	}
+	changedSet := toSet(changed)
+
+	names := migrationFilenames(d.config)
+	if err := checkBaseNameRows(applied, names); err != nil {
+		return err
+	}
+	if len(names) == 0 && !porcelain {
+		d.println(noMigrationsFound(d.config))
+		return nil
+	}
+
+	if sinceVersion != "" {
+		if names, err = appliedSince(applied, sinceVersion); err != nil {
+			return err
+		}
+	}
+
+	excludedSet := map[string]bool{}
+	if exclude != "" {
+		_, excluded, err := excludeMatching(d, append(diffOf(names, applied), changed...), exclude)
+		if err != nil {
+			return err
+		}
+		excludedSet = toSet(excluded)
+	}
+
+	var times map[string]time.Time
+	if porcelain {
+		if times, err = appliedTimes(d); err != nil {
+			return err
+		}
+	}
+	porcelainApplied, porcelainPending := make([]string, 0), make([]string, 0)
+
+	for _, fname := range names {
+		if since != "" || before != "" {
+			t, ok := migrationTime(fname)
+			if !ok {
+				continue
+			}
+			if since != "" && t.Before(sinceTime) {
+				continue
+			}
+			if before != "" && !t.Before(beforeTime) {
+				continue
+			}
+		}
+
+		state := "pending"
+		if appliedSet[fname] {
+			state = "applied"
+			if changedSet[fname] {
+				state = "changed"
+			}
+		}
+		if excludedSet[fname] {
+			state = "excluded"
+		}
+		if porcelain {
+			if appliedSet[fname] && !excludedSet[fname] {
+				porcelainApplied = append(porcelainApplied, fname)
+			} else {
+				porcelainPending = append(porcelainPending, fname)
+			}
+			continue
+		}
+		d.printf("%s\t%-40s\t%s\n", paint(colorStdout, stateColor(state), state), migrationTitle(fname), fname)
+	}
+
+	if porcelain {
+		printPorcelain(d, porcelainApplied, porcelainPending, times)
+	}
+
+	return nil
+}
+
+// appliedSince returns the migrations of applied, in the order they were
+// applied, that come after baseline.
+func appliedSince(applied []string, baseline string) ([]string, error) {
+	for i, fname := range applied {
+		if fname == baseline {
+			return applied[i+1:], nil
+		}
+	}
+
+	return nil, fmt.Errorf("%s isn't an applied migration, --since-version needs one", baseline)
+}
+
+// CurrentMigration returns the name of the most recently applied migration,
+// or an empty string when none has been applied yet.
+func (d *Dbmig) CurrentMigration() (string, error) {
+	name, _, err := currentMigration(d)
+	return name, err
+}
+
+// currentMigration returns the name of the most recently applied migration
+// and how many seconds ago it was applied.
+func currentMigration(d *Dbmig) (name string, age int64, err error) {
+	query := fmt.Sprintf("SELECT name, %s FROM %s%s ORDER BY version DESC, id DESC LIMIT 1", fmt.Sprintf(d.dialect().secondsSince, "created_at"), d.config.trackingTable(), d.config.whereApplied())
+
+	ctx, cancel := d.statementContext()
+	defer cancel()
+
+	err = d.db.QueryRowContext(ctx, query).Scan(&name, &age)
+	if err == sql.ErrNoRows {
+		return "", 0, nil
+	}
+	if err != nil {
+		return "", 0, trackingTableError(d, err)
+	}
+
+	return name, age, nil
+}
+
+// humanAge formats a number of seconds like "3 days ago".
+func humanAge(seconds int64) string {
+	units := []struct {
+		name    string
+		seconds int64
+	}{{"day", 86400}, {"hour", 3600}, {"minute", 60}, {"second", 1}}
+
+	if seconds < 0 {
+		seconds = 0
+	}
+
+	for _, unit := range units {
+		if n := seconds / unit.seconds; n >= 1 || unit.seconds == 1 {
+			if n == 1 {
+				return fmt.Sprintf("1 %s ago", unit.name)
+			}
+			return fmt.Sprintf("%d %ss ago", n, unit.name)
+		}
+	}
+
+	return ""
+}
+
+func (d *Dbmig) Current(args []string) error {
+	if len(args) == 0 || args[0] != "current" {
+		return fmt.Errorf("Invalid call %v", args)
+	}
+
+	var withAge, seconds bool
+	fs := flag.NewFlagSet("current", flag.ContinueOnError)
+	fs.BoolVar(&withAge, "with-age", false, "Also print how long ago it was applied")
+	fs.BoolVar(&seconds, "seconds", false, "Print the age in seconds, for monitoring (implies --with-age)")
+
+	if _, err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+
+	name, age, err := currentMigration(d)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case name == "":
+		d.println("none")
+	case seconds:
+		d.printf("%s\t%d\n", name, age)
+	case withAge:
+		d.printf("%s\t%s\n", name, humanAge(age))
+	default:
+		d.println(name)
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// defaultStreamThreshold is the file size above which migrations are
+// streamed statement by statement instead of read in memory.
+const defaultStreamThreshold int64 = 64 << 20
+
+// streamHeaderSize is how much of a streamed migration is searched for
+// directives; they must be in its first 64 KiB.
+const streamHeaderSize int = 64 << 10
+
+func (c *Config) streamThreshold() int64 {
+	if c.StreamThreshold <= 0 {
+		return defaultStreamThreshold
+	}
+
+	return c.StreamThreshold
+}
+
+// crlfReader turns the CRLF line endings of migrations authored on Windows
+// into LF while reading, like readMigration does.
+type crlfReader struct {
+	r  *bufio.Reader
+	cr bool
+}
+
+func (c *crlfReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			if c.cr {
+				p[n] = '\r'
+				n++
+				c.cr = false
+			}
+			if n > 0 && err == io.EOF {
+				return n, nil
+			}
+			return n, err
+		}
+
+		if c.cr {
+			c.cr = false
+			if b != '\n' {
+				p[n] = '\r'
+				n++
+				if n == len(p) {
+					c.r.UnreadByte()
+					return n, nil
+				}
+			}
+		}
+		if b == '\r' {
+			c.cr = true
+			continue
+		}
+
+		p[n] = b
+		n++
+	}
+
+	return n, nil
+}
+
+// applyMigrationStream applies a large migration file, executing the
+// statements of the section being applied as they are read. The checksum
+// covers the whole file, like for migrations read in memory.
+func applyMigrationStream(d *Dbmig, fname string, src io.Reader, direction string) error {
+	hash := sha256.New()
+	r := bufio.NewReaderSize(io.TeeReader(&crlfReader{r: bufio.NewReader(src)}, hash), streamHeaderSize)
+
+	head, err := r.Peek(streamHeaderSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return err
+	}
+	m := &migration{Name: fname, directives: parseDirectives(string(head))}
+
+	// Only the up section of a streamed migration can be a copy section, the
+	// down header isn't known before reading up to it.
+	upHead := string(head)
+	if i := strings.Index(upHead, migrationSeparator); i >= 0 {
+		upHead = upHead[:i]
+	}
+	upCopy, err := sectionCopyTarget(upHead)
+	if err != nil {
+		return fmt.Errorf("%s: %v", fname, err)
+	}
+
+	if direction == "down" && m.forwardOnly() {
+		return fmt.Errorf("Migration %s is forward-only and can't be migrated down", fname)
+	}
+
+	if d.maxStatements() > 0 {
+		f, err := openMigrationFile(d.config, fname)
+		if err != nil {
+			return err
+		}
+		err = checkStatementCount(d, fname, &crlfReader{r: bufio.NewReader(f)}, direction)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	d.logf("Applying: %s (%s), streaming it statement by statement", fname, direction)
+
+	return runMigrationTx(d, m, direction, func(ctx context.Context, tx *sql.Tx, timeout time.Duration) error {
+		section := "up"
+		if upCopy != nil {
+			if direction == "up" {
+				separator, err := copyRows(ctx, d, tx, fname, upCopy, r)
+				if err != nil {
+					return err
+				}
+				if !separator && !m.forwardOnly() {
+					return fmt.Errorf("Migration %s must contain the %s separator exactly once", fname, migrationSeparator)
+				}
+				_, err = io.Copy(ioutil.Discard, r)
+				return err
+			}
+			if err := skipToSeparator(r); err != nil {
+				return fmt.Errorf("Migration %s must contain the %s separator exactly once", fname, migrationSeparator)
+			}
+			section = "down"
+		}
+
+		scanner := newStatementScanner(r)
+		executed := 0
+		for {
+			stmt, separator, err := scanner.next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("Reading %s: %v", fname, err)
+			}
+
+			if section == direction && stmt != "" {
+				executed++
+				if err := runStatement(ctx, d, tx, fname, direction, stmt, executed, 0, timeout); err != nil {
+					return err
+				}
+			}
+
+			if separator {
+				if section == "down" {
+					return fmt.Errorf("Migration %s must contain the %s separator exactly once", fname, migrationSeparator)
+				}
+				section = "down"
+				if direction == "up" {
+					break
+				}
+			}
+		}
+
+		if section == "up" && !m.forwardOnly() {
+			return fmt.Errorf("Migration %s must contain the %s separator exactly once", fname, migrationSeparator)
+		}
+
+		// Read the rest so the checksum covers the whole file.
+		if _, err := io.Copy(ioutil.Discard, r); err != nil {
+			return err
+		}
+
+		d.logf("Executed %d statements of %s", executed, fname)
+		return nil
+	}, func() string {
+		return hex.EncodeToString(hash.Sum(nil))
+	})
+}
+
+// skipToSeparator reads r up to the end of the line with the separator.
+func skipToSeparator(r *bufio.Reader) error {
+	for {
+		line, err := r.ReadString('\n')
+		if strings.Contains(line, migrationSeparator) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func execStatement(ctx context.Context, d *Dbmig, tx *sql.Tx, stmt string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, stmt)
+	return err
+}
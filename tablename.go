@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var dbnameKeywordRe = regexp.MustCompile(`(?:^|\s)dbname\s*=\s*'?([^'\s]+)`)
+
+// connectionDatabase returns the database name of a connection string: the
+// path of a URL, the dbname of keyword/value pairs, or the file name without
+// extension of an SQLite file.
+func connectionDatabase(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		if u.Scheme == "file" {
+			p := u.Opaque
+			if p == "" {
+				p = u.Path
+			}
+			base := filepath.Base(p)
+			return strings.TrimSuffix(base, filepath.Ext(base))
+		}
+		return strings.TrimPrefix(u.Path, "/")
+	}
+
+	if m := dbnameKeywordRe.FindStringSubmatch(dsn); m != nil {
+		return m[1]
+	}
+
+	return ""
+}
+
+// expandTableTemplate expands db_dbmi_tablename_template, replacing {db}
+// with the database name from the connection string.
+func expandTableTemplate(template string, dsn string) (string, error) {
+	name := template
+	if strings.Contains(template, "{db}") {
+		db := connectionDatabase(dsn)
+		if db == "" {
+			return "", fmt.Errorf("db_dbmi_tablename_template uses {db}, but the connection string names no database")
+		}
+		name = strings.Replace(template, "{db}", db, -1)
+	}
+
+	if !identifierRe.MatchString(name) {
+		return "", fmt.Errorf("db_dbmi_tablename_template expands to %q, which is not a valid table name", name)
+	}
+
+	return name, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// migrationTarget works out how `migrate to <target>` reaches target: by
+// migrating up through it when it is pending, or down to it, leaving it
+// applied, when it is applied. direction is empty when target is the latest
+// applied migration already.
+func migrationTarget(d *Dbmig, target string, dirOrder string) (direction string, amount int, err error) {
+	// The target may be given by its path, as shells complete it.
+	if rel, err := filepath.Rel(d.config.Folder, target); err == nil && !strings.HasPrefix(rel, "..") {
+		target = filepath.ToSlash(rel)
+	}
+
+	known := false
+	for _, fname := range migrationFilenames(d.config) {
+		if fname == target {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return "", 0, fmt.Errorf("Unknown migration %s, expected a file in %s", target, d.config.Folder)
+	}
+	if isRepeatable(d, target) {
+		return "", 0, fmt.Errorf("%s is repeatable, it isn't part of the history migrate to moves along", target)
+	}
+
+	pending, _, err := pendingMigrations(d)
+	if err != nil {
+		return "", 0, err
+	}
+	for i, fname := range pending {
+		if fname == target {
+			return "up", i + 1, nil
+		}
+	}
+
+	applied, err := downMigrations(d, 0, dirOrder)
+	if err != nil {
+		return "", 0, err
+	}
+	for i, fname := range applied {
+		if fname == target {
+			if i == 0 {
+				return "", 0, nil
+			}
+			return "down", i, nil
+		}
+	}
+
+	return "", 0, fmt.Errorf("%s is neither pending nor applied", target)
+}
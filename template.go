@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// renderTemplate renders a migration as a Go template with the
+// db_template_vars of the config, when -template-vars is on. It is off by
+// default, as migrations may contain literal braces.
+func renderTemplate(c *Config, fname string, data string) (string, error) {
+	if !c.renderTemplates {
+		return data, nil
+	}
+
+	t, err := template.New(fname).Option("missingkey=error").Parse(data)
+	if err != nil {
+		return "", fmt.Errorf("Parsing migration %s as a template: %v", fname, err)
+	}
+
+	var b strings.Builder
+	if err := t.Execute(&b, c.TemplateVars); err != nil {
+		return "", fmt.Errorf("Rendering migration %s: %v", fname, err)
+	}
+
+	return b.String(), nil
+}
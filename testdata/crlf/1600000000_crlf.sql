@@ -0,0 +1,5 @@
+CREATE TABLE items (
+  id INTEGER
+);
+/*DOWN*/
+DROP TABLE items;
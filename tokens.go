@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"os/user"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Under migrate --enable-tokens, these tokens in migrations expand to
+// quoted SQL literals, for audit columns in data migrations.
+const (
+	tokenNow  string = ":dbmi_now"
+	tokenUser string = ":dbmi_user"
+)
+
+// tokenRe matches the tokens as whole words, so :dbmi_username is left as is.
+var tokenRe = regexp.MustCompile(`:dbmi_(now|user)\b`)
+
+// applyingUser names the user running dbmi.
+func applyingUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+
+	return os.Getenv("USER")
+}
+
+func quoteLiteral(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// expandTokens replaces the tokens in stmt with the current UTC time and
+// the applying user. Tokens in string literals, quoted identifiers and
+// comments are left alone. Checksums are taken before, so they stay stable.
+func expandTokens(stmt string, now time.Time) string {
+	values := map[string]string{
+		tokenNow:  quoteLiteral(now.UTC().Format("2006-01-02 15:04:05")),
+		tokenUser: quoteLiteral(applyingUser()),
+	}
+
+	var out, code strings.Builder
+	flush := func() {
+		out.WriteString(tokenRe.ReplaceAllStringFunc(code.String(), func(token string) string {
+			return values[token]
+		}))
+		code.Reset()
+	}
+
+	// The scanner copies quotes and comments through as they are. An
+	// unterminated one is copied to the end, for the database to reject.
+	s := newStatementScanner(strings.NewReader(stmt))
+	for {
+		r, err := s.read()
+		if err != nil {
+			break
+		}
+
+		switch {
+		case r == '\'':
+			flush()
+			escapes := (s.prev == 'e' || s.prev == 'E') && !isIdentRune(s.beforePrev)
+			out.WriteRune(r)
+			s.quoted(&out, '\'', escapes)
+		case r == '"':
+			flush()
+			out.WriteRune(r)
+			s.quoted(&out, '"', false)
+		case r == '-' && s.peekIs("-"):
+			flush()
+			out.WriteRune(r)
+			s.lineComment(&out)
+		case r == '/' && s.peekIs("*"):
+			flush()
+			out.WriteRune(r)
+			s.blockComment(&out)
+		case r == '$' && !isIdentRune(s.prev):
+			flush()
+			out.WriteRune(r)
+			s.dollarQuoted(&out)
+		default:
+			code.WriteRune(r)
+		}
+		s.saw(r)
+	}
+	flush()
+
+	return out.String()
+}
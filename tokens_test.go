@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpandTokens(t *testing.T) {
+	now := time.Date(2021, 1, 4, 10, 15, 0, 0, time.UTC)
+	user := quoteLiteral(applyingUser())
+
+	for stmt, want := range map[string]string{
+		"UPDATE t SET at = :dbmi_now, by = :dbmi_user;":       "UPDATE t SET at = '2021-01-04 10:15:00', by = " + user + ";",
+		"SELECT :dbmi_username, :dbmi_nowish;":                "SELECT :dbmi_username, :dbmi_nowish;",
+		"SELECT ':dbmi_now', \":dbmi_user\", $$:dbmi_now$$":   "SELECT ':dbmi_now', \":dbmi_user\", $$:dbmi_now$$",
+		"-- set :dbmi_now\nSELECT /* :dbmi_user */ :dbmi_now": "-- set :dbmi_now\nSELECT /* :dbmi_user */ '2021-01-04 10:15:00'",
+		"SELECT E'it\\'s :dbmi_now', :dbmi_now":               "SELECT E'it\\'s :dbmi_now', '2021-01-04 10:15:00'",
+	} {
+		if got := expandTokens(stmt, now); got != want {
+			t.Errorf("expandTokens(%q) = %q, want %q", stmt, got, want)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// emptyDown reports whether the down section of m is empty or only has
+// comments, without m being marked forward-only or irreversible. Such a
+// migration can't be rolled back, which tends to show only in an emergency.
+func emptyDown(m *migration) bool {
+	return onlyComments(m.Down) && !m.forwardOnly() && !m.irreversible()
+}
+
+// missingDowns returns the migrations of names with an empty down section.
+func missingDowns(d *Dbmig, names []string) []string {
+	missing := make([]string, 0)
+	for _, fname := range names {
+		if isGoMigration(fname) {
+			continue
+		}
+
+		data, err := readMigrationFile(d, fname)
+		if err != nil {
+			continue
+		}
+		if m, ok := parseMigration(fname, data); ok && emptyDown(m) {
+			missing = append(missing, fname)
+		}
+	}
+
+	return missing
+}
+
+// checkMissingDowns warns about the migrations of batch with an empty down
+// section before migrate up applies them, or refuses them under --strict.
+func checkMissingDowns(d *Dbmig, batch []string, strict bool) error {
+	missing := missingDowns(d, batch)
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if strict {
+		return fmt.Errorf("--strict: migrations with an empty down section: %s. Write it, or mark them `%sirreversible`", strings.Join(missing, ", "), directivePrefix)
+	}
+	for _, fname := range missing {
+		d.logf("Warning: %s has an empty down section and can't be rolled back; mark it `%sirreversible` if that is intended", fname, directivePrefix)
+	}
+
+	return nil
+}
+
+// validateMigration returns the problems found in a single migration file.
+func validateMigration(d *Dbmig, fname string) []string {
+	if isGoMigration(fname) {
+		return nil
+	}
+
+	data, err := readMigrationFile(d, fname)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	m, ok := parseMigration(fname, data)
+	if !ok {
+		return []string{fmt.Sprintf("must contain the %s separator exactly once, or none with a `%sforward-only` header", migrationSeparator, directivePrefix)}
+	}
+
+	problems := make([]string, 0)
+	if limit := d.config.MaxMigrationSize; limit > 0 && int64(len(data)) > limit {
+		problems = append(problems, fmt.Sprintf("is %d bytes, more than the %d bytes of max_migration_bytes", len(data), limit))
+	}
+	if v, ok := m.directive("isolation"); ok {
+		if _, err := parseIsolationLevel(v); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if v, ok := m.directive("timeout"); ok {
+		if _, err := parseTimeout(v); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	for _, section := range []string{m.Up, m.Down} {
+		if _, err := sectionCopyTarget(section); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	return problems
+}
+
+// Validate checks every migration file without touching the database.
+func (d *Dbmig) Validate(args []string) error {
+	if len(args) == 0 || args[0] != "validate" {
+		return fmt.Errorf("Invalid call %v", args)
+	}
+
+	var strict bool
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	fs.BoolVar(&strict, "strict", false, "Count migrations with an empty down section as invalid, not just warn")
+
+	if _, err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+
+	names := migrationFilenames(d.config)
+	missing := toSet(missingDowns(d, names))
+	invalid := 0
+	for _, fname := range names {
+		problems := validateMigration(d, fname)
+		for _, problem := range problems {
+			d.printf("%s: %s\n", fname, problem)
+		}
+		if missing[fname] {
+			if strict {
+				problems = append(problems, "empty down section")
+			}
+			d.printf("%s: warning: empty down section, mark it `%sirreversible` if it can't be rolled back\n", fname, directivePrefix)
+		}
+		if len(problems) > 0 {
+			invalid++
+		}
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d migrations are invalid", invalid, len(names))
+	}
+	d.printf("%d migrations are valid\n", len(names))
+
+	return nil
+}
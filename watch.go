@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Watch polls the migrations folder and runs `migrate up 1` for every new
+// migration file, once the file has stopped changing for the debounce
+// period. It's a development convenience and runs until interrupted.
+func (d *Dbmig) Watch(args []string) error {
+	if len(args) == 0 || args[0] != "watch" {
+		return fmt.Errorf("Invalid call %v", args)
+	}
+
+	var interval, debounce time.Duration
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	fs.DurationVar(&interval, "interval", time.Second, "How often to look for new migrations")
+	fs.DurationVar(&debounce, "debounce", 500*time.Millisecond, "How long a new file must stay unchanged before it's applied")
+
+	if _, err := parseFlags(fs, args[1:]); err != nil {
+		return err
+	}
+
+	known := toSet(migrationFilenames(d.config))
+	// seen holds new files that are still being written, with the time they
+	// last changed and their size then.
+	type change struct {
+		at   time.Time
+		size int64
+	}
+	seen := map[string]change{}
+
+	d.logf("Watching %s for new migrations, press Ctrl-C to stop", d.config.Folder)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.context().Done():
+			return d.context().Err()
+		case <-ticker.C:
+		}
+
+		for _, fname := range migrationFilenames(d.config) {
+			if known[fname] || isGoMigration(fname) {
+				continue
+			}
+
+			info, err := os.Stat(filepath.Join(d.config.Folder, fname))
+			if err != nil {
+				continue
+			}
+
+			last, ok := seen[fname]
+			if !ok || last.size != info.Size() || info.ModTime().After(last.at) {
+				seen[fname] = change{at: time.Now(), size: info.Size()}
+				continue
+			}
+			if time.Since(last.at) < debounce {
+				continue
+			}
+
+			known[fname] = true
+			delete(seen, fname)
+
+			d.logf("New migration %s, running migrate up 1", fname)
+			if err := d.Migrate([]string{"migrate", "up", "1"}); err != nil {
+				d.logf("Auto-apply after %s failed: %v", fname, err)
+				continue
+			}
+			d.logf("Auto-applied after %s", fname)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// webhookTimeout bounds the delivery of a webhook, so a slow receiver
+// doesn't hold up the end of a run.
+const webhookTimeout time.Duration = 5 * time.Second
+
+// webhookPayload is POSTed to webhook_url after each migrate run.
+type webhookPayload struct {
+	Command         string   `json:"command"`
+	Table           string   `json:"table"`
+	Applied         []string `json:"applied"`
+	Failed          []string `json:"failed"`
+	DurationSeconds float64  `json:"duration_seconds"`
+	Success         bool     `json:"success"`
+	Error           string   `json:"error,omitempty"`
+}
+
+func validateWebhookURL(c *Config) error {
+	if c.WebhookURL == "" {
+		return nil
+	}
+
+	u, err := url.Parse(c.WebhookURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("Invalid webhook_url, expected an http or https URL")
+	}
+
+	return nil
+}
+
+// notifyWebhook tells webhook_url how the run of args went. Delivery is best
+// effort: a failure is logged and doesn't change the outcome of the run.
+func notifyWebhook(d *Dbmig, args []string, result *batchResult, duration time.Duration, runErr error) {
+	payload := webhookPayload{
+		Command:         fmt.Sprintf("%s %s", programName, strings.Join(args, " ")),
+		Table:           d.config.trackingTableName(),
+		Applied:         append([]string{}, result.succeeded...),
+		Failed:          append([]string{}, result.failed...),
+		DurationSeconds: duration.Seconds(),
+		Success:         runErr == nil,
+	}
+	if runErr != nil {
+		payload.Error = runErr.Error()
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		d.logf("Webhook not delivered: %v", err)
+		return
+	}
+
+	// The run's context may be done already, when it timed out, and that is
+	// worth reporting too.
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.config.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		d.logf("Webhook not delivered: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	// The URL often carries a token, so only its host is logged.
+	host := req.URL.Host
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		d.logf("Webhook to %s not delivered: %v", host, redactURLError(err))
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		d.logf("Webhook to %s not delivered: %s", host, resp.Status)
+	}
+}
+
+// redactURLError drops the URL from the errors of http.Client, keeping what
+// went wrong.
+func redactURLError(err error) error {
+	if uerr, ok := err.(*url.Error); ok {
+		return uerr.Err
+	}
+
+	return err
+}